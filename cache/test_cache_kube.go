@@ -3,7 +3,6 @@ package main
 import (
 	internal "demokubenet/internal"
 	"log"
-	"sync"
 	"time"
 )
 
@@ -20,12 +19,12 @@ func main() {
 	// 启动scheduler
 	inst.Start()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-
-	inst.Scheduler.PublishWithWait(internal.Event{Type: internal.EasyEvent}, &wg)
-
-	wg.Wait()
+	if err := inst.Scheduler.Publish(inst.NewTickEvent(internal.EasyEvent)).Wait(); err != nil {
+		log.Printf("failed to calculate links: %v", err)
+	}
+	if err := inst.Close(); err != nil {
+		log.Printf("failed to close EmulationInstance: %v", err)
+	}
 
 	// 验证所有LinkCache中的Ar被更新
 	// links := inst.GetSatelliteLinks()
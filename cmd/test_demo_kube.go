@@ -3,7 +3,6 @@ package main
 import (
 	internal "demokubenet/internal"
 	"log"
-	"sync"
 	"time"
 )
 
@@ -16,16 +15,20 @@ func main() {
 		if err != nil {
 			log.Printf("failed to create EmulationInstance: %v", err)
 		}
+		// 加入沿航线运动的船舶、飞机和车辆终端
+		if _, err := inst.LoadMobileStations("data/mobile_terminals.txt"); err != nil {
+			log.Printf("failed to load mobile terminals: %v", err)
+		}
 
 		// 启动scheduler
 		inst.Start()
 
-		var wg sync.WaitGroup
-		wg.Add(1)
-
-		inst.Scheduler.PublishWithWait(internal.Event{Type: internal.EasyEvent}, &wg)
-
-		wg.Wait()
+		if err := inst.Scheduler.Publish(inst.NewTickEvent(internal.EasyEvent)).Wait(); err != nil {
+			log.Printf("failed to calculate links: %v", err)
+		}
+		if err := inst.Close(); err != nil {
+			log.Printf("failed to close EmulationInstance: %v", err)
+		}
 
 		// 验证所有LinkCache中的Ar被更新
 		// links := inst.GetSatelliteLinks()
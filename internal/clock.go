@@ -0,0 +1,118 @@
+package demokubenet
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Clock 为仿真提供当前时刻，所有更新步骤都使用 Event 中携带的时刻，
+// 而不是直接调用 time.Now()，这样从同一起始历元出发的运行可以复现
+type Clock interface {
+	// Now 返回当前仿真时刻
+	Now() time.Time
+	// Tick 返回本轮更新使用的时刻，并推进到下一轮
+	Tick() time.Time
+	// Until 返回到达仿真时刻 t 需要等待的真实时长，离散仿真时钟总是返回 0
+	Until(t time.Time) time.Duration
+	// AdvanceTo 将时钟推进到 t，跟随真实时间的时钟忽略该调用
+	AdvanceTo(t time.Time)
+}
+
+// RealClock 直接使用系统时间
+type RealClock struct{}
+
+func NewRealClock() *RealClock {
+	return &RealClock{}
+}
+
+func (c *RealClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+func (c *RealClock) Tick() time.Time {
+	return c.Now()
+}
+
+func (c *RealClock) Until(t time.Time) time.Duration {
+	return t.Sub(c.Now())
+}
+
+func (c *RealClock) AdvanceTo(t time.Time) {}
+
+// SimulatedClock 以固定步长推进的仿真时钟，与真实时间无关。
+// Until 总是返回 0，调度器不会等待：定时事件一个接一个同步执行，两轮之间只穿插处理已排队的事件。
+// 不限次数的 ScheduleEvery 会一直占满调度协程，直到 Cancel 或 Stop；需要按真实时间节奏运行时使用 AcceleratedClock
+type SimulatedClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func NewSimulatedClock(start time.Time, step time.Duration) *SimulatedClock {
+	return &SimulatedClock{
+		now:  start.UTC(),
+		step: step,
+	}
+}
+
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *SimulatedClock) Tick() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.now
+	c.now = c.now.Add(c.step)
+	return t
+}
+
+func (c *SimulatedClock) Until(t time.Time) time.Duration {
+	return 0
+}
+
+func (c *SimulatedClock) AdvanceTo(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// AcceleratedClock 从起始历元出发，以真实时间的 factor 倍速前进
+type AcceleratedClock struct {
+	start     time.Time
+	wallStart time.Time
+	factor    float64
+}
+
+// NewAcceleratedClock factor 必须为正的有限值
+func NewAcceleratedClock(start time.Time, factor float64) (*AcceleratedClock, error) {
+	if !(factor > 0) || math.IsInf(factor, 0) {
+		return nil, fmt.Errorf("accelerated clock: invalid factor %v", factor)
+	}
+	return &AcceleratedClock{
+		start:     start.UTC(),
+		wallStart: time.Now(),
+		factor:    factor,
+	}, nil
+}
+
+func (c *AcceleratedClock) Now() time.Time {
+	elapsed := time.Since(c.wallStart)
+	return c.start.Add(time.Duration(float64(elapsed) * c.factor))
+}
+
+func (c *AcceleratedClock) Tick() time.Time {
+	return c.Now()
+}
+
+func (c *AcceleratedClock) Until(t time.Time) time.Duration {
+	return time.Duration(float64(t.Sub(c.Now())) / c.factor)
+}
+
+func (c *AcceleratedClock) AdvanceTo(t time.Time) {}
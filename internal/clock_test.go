@@ -0,0 +1,96 @@
+package demokubenet
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var clockStart = time.Date(2025, 7, 8, 0, 0, 0, 0, time.UTC)
+
+func TestSimulatedClock(t *testing.T) {
+	c := NewSimulatedClock(clockStart.In(time.FixedZone("UTC+8", 8*3600)), time.Minute)
+
+	if got := c.Now(); !got.Equal(clockStart) || got.Location() != time.UTC {
+		t.Fatalf("Now() = %v, want %v in UTC", got, clockStart)
+	}
+	for i := 0; i < 3; i++ {
+		want := clockStart.Add(time.Duration(i) * time.Minute)
+		if got := c.Tick(); !got.Equal(want) {
+			t.Fatalf("tick %d = %v, want %v", i, got, want)
+		}
+	}
+	if got := c.Now(); !got.Equal(clockStart.Add(3 * time.Minute)) {
+		t.Errorf("Now() after 3 ticks = %v", got)
+	}
+	if d := c.Until(clockStart.Add(time.Hour)); d != 0 {
+		t.Errorf("Until() = %v, want 0", d)
+	}
+
+	// AdvanceTo 只向前推进
+	c.AdvanceTo(clockStart.Add(time.Hour))
+	c.AdvanceTo(clockStart)
+	if got := c.Now(); !got.Equal(clockStart.Add(time.Hour)) {
+		t.Errorf("Now() after AdvanceTo = %v, want %v", got, clockStart.Add(time.Hour))
+	}
+}
+
+func TestSimulatedClockConcurrentTicks(t *testing.T) {
+	c := NewSimulatedClock(clockStart, time.Second)
+	const n = 100
+	seen := make(chan time.Time, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen <- c.Tick()
+		}()
+	}
+	wg.Wait()
+	close(seen)
+
+	// 并发的 Tick 各自得到不同的时刻
+	times := make(map[time.Time]bool)
+	for ts := range seen {
+		if times[ts] {
+			t.Fatalf("tick %v returned twice", ts)
+		}
+		times[ts] = true
+	}
+	if got := c.Now(); !got.Equal(clockStart.Add(n * time.Second)) {
+		t.Errorf("Now() = %v after %d ticks", got, n)
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	c := NewRealClock()
+	before := time.Now()
+	now := c.Now()
+	if now.Location() != time.UTC || now.Before(before.Add(-time.Second)) {
+		t.Errorf("Now() = %v", now)
+	}
+	if d := c.Until(now.Add(time.Hour)); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("Until(now+1h) = %v", d)
+	}
+}
+
+func TestInstanceTickEventsFollowClock(t *testing.T) {
+	sats, stations := testNodes()
+	inst, err := newEmulationInstance(stations, sats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.SetClock(NewSimulatedClock(clockStart, 10*time.Second))
+
+	for i := 0; i < 3; i++ {
+		evt := inst.NewTickEvent(EasyEvent)
+		if want := clockStart.Add(time.Duration(i) * 10 * time.Second); !evt.Time.Equal(want) {
+			t.Fatalf("tick %d at %v, want %v", i, evt.Time, want)
+		}
+	}
+	// 没有时刻的事件使用时钟的当前时刻
+	if got := inst.eventTime(Event{Type: EasyEvent}); !got.Equal(clockStart.Add(30 * time.Second)) {
+		t.Errorf("eventTime of untimed event = %v", got)
+	}
+}
@@ -0,0 +1,34 @@
+package demokubenet
+
+import "math"
+
+// rangeRate 由两端的 ECEF 运动状态计算斜距变化率 (m/s) 及其变化率 (m/s²)：
+// ρ̇ = Δr·Δv/ρ，ρ̈ = (|Δv|² + Δr·Δa - ρ̇²)/ρ
+func rangeRate(station, sat ecefState) (float64, float64) {
+	var dr, dv, da [3]float64
+	for i := 0; i < 3; i++ {
+		dr[i] = sat.R[i] - station.R[i]
+		dv[i] = sat.V[i] - station.V[i]
+		da[i] = sat.A[i] - station.A[i]
+	}
+	rho := math.Sqrt(dot(dr, dr))
+	if rho == 0 {
+		return 0, 0
+	}
+	rate := dot(dr, dv) / rho
+	accel := (dot(dv, dv) + dot(dr, da) - rate*rate) / rho
+	return rate, accel
+}
+
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// updateDoppler 计算链路的斜距变化率和载波频率 f (GHz) 上的多普勒频移，
+// 卫星接近时 (ρ̇ < 0) 频移为正
+func updateDoppler(link *LinkCache, station, sat ecefState, f float64) {
+	rate, accel := rangeRate(station, sat)
+	link.RangeRate = rate
+	link.Doppler = -rate / SpeedOfLight * f * 1e9
+	link.DopplerRate = -accel / SpeedOfLight * f * 1e9
+}
@@ -0,0 +1,112 @@
+package demokubenet
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDopplerSign(t *testing.T) {
+	const (
+		f     = 20.0 // GHz
+		speed = 7500.0
+		re    = 6378137.0
+		h     = 550e3
+	)
+	station := ecefState{R: [3]float64{re, 0, 0}}
+	// 卫星在 550 km 高度沿 +Y 飞过地面站天顶
+	pass := func(y float64) ecefState {
+		return ecefState{R: [3]float64{re + h, y, 0}, V: [3]float64{0, speed, 0}}
+	}
+	// 卫星沿视线方向运动时频移最大，|Δf| = v·f/c
+	lineOfSight := func(sign float64) ecefState {
+		d := [3]float64{h, -1000e3, 0}
+		n := norm3(d)
+		return ecefState{R: [3]float64{re + d[0], d[1], 0}, V: [3]float64{sign * speed * d[0] / n, sign * speed * d[1] / n, 0}}
+	}
+	maxShift := speed / SpeedOfLight * f * 1e9
+
+	cases := []struct {
+		name     string
+		sat      ecefState
+		min, max float64 // Hz
+	}{
+		{"approaching", pass(-1000e3), 0.8 * maxShift, maxShift},
+		{"receding", pass(1000e3), -maxShift, -0.8 * maxShift},
+		{"zenith", pass(0), -1e-6, 1e-6},
+		{"head-on", lineOfSight(-1), maxShift * (1 - 1e-12), maxShift * (1 + 1e-12)},
+		{"directly away", lineOfSight(1), -maxShift * (1 + 1e-12), -maxShift * (1 - 1e-12)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var link LinkCache
+			updateDoppler(&link, station, c.sat, f)
+			if link.Doppler < c.min || link.Doppler > c.max {
+				t.Errorf("Doppler %g Hz, want in [%g, %g]", link.Doppler, c.min, c.max)
+			}
+			if got := -link.RangeRate / SpeedOfLight * f * 1e9; got != link.Doppler {
+				t.Errorf("Doppler %g Hz does not match range rate %g m/s", link.Doppler, link.RangeRate)
+			}
+		})
+	}
+
+	// 过天顶时频移随时间由正变负，变化率为负且与差分一致
+	var at, next LinkCache
+	updateDoppler(&at, station, pass(0), f)
+	updateDoppler(&next, station, pass(speed*1e-3), f)
+	if at.DopplerRate >= 0 {
+		t.Errorf("Doppler rate at zenith %g Hz/s, want negative", at.DopplerRate)
+	}
+	if fd := (next.Doppler - at.Doppler) / 1e-3; math.Abs(fd-at.DopplerRate) > 1e-3*math.Abs(at.DopplerRate) {
+		t.Errorf("Doppler rate %g Hz/s, finite difference %g Hz/s", at.DopplerRate, fd)
+	}
+}
+
+func TestLinkDopplerMatchesRange(t *testing.T) {
+	const f = 20.0
+	ts := satelliteEpochs[0].Add(10 * time.Minute)
+	sats, _ := testNodes()
+	sub := sats[0].GetPosition(ts)
+	// 星下点附近的几个站，链路都可见
+	var stations []*Station
+	for _, d := range []float64{-8, -3, 3, 8} {
+		stations = append(stations, NewStation(Position{Latitude: sub.Latitude + d, Longitude: sub.Longitude + d}))
+	}
+	inst, err := newEmulationInstance(stations, sats[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges := func(ts time.Time) []LinkCache {
+		if err := inst.CalculateLinks(ts, LinkUpdatePayload{Frequency: f}); err != nil {
+			t.Fatal(err)
+		}
+		return append([]LinkCache(nil), inst.Links...)
+	}
+	before, at, after := ranges(ts.Add(-time.Second)), ranges(ts), ranges(ts.Add(time.Second))
+
+	v := sats[0].GetVelocity(ts)
+	vmax := norm3([3]float64{v.X, v.Y, v.Z})
+	for i := range at {
+		t.Run(fmt.Sprintf("station%d", i), func(t *testing.T) {
+			if !at[i].Active {
+				t.Fatalf("link inactive at elevation %g", at[i].Elevation)
+			}
+			// 斜距变化率与斜距的差分一致，接近 (ρ̇ < 0) 时频移为正
+			fd := (after[i].Range - before[i].Range) / 2
+			if math.Abs(at[i].RangeRate-fd) > 1 {
+				t.Errorf("range rate %g m/s, finite difference %g m/s", at[i].RangeRate, fd)
+			}
+			if (at[i].Doppler > 0) != (fd < 0) {
+				t.Errorf("Doppler %g Hz with range changing at %g m/s", at[i].Doppler, fd)
+			}
+			if math.Abs(at[i].RangeRate) > vmax {
+				t.Errorf("|range rate| %g m/s exceeds the satellite speed %g m/s", math.Abs(at[i].RangeRate), vmax)
+			}
+			fdRate := (after[i].Doppler - before[i].Doppler) / 2
+			if math.Abs(at[i].DopplerRate-fdRate) > 0.01*math.Abs(fdRate)+1 {
+				t.Errorf("Doppler rate %g Hz/s, finite difference %g Hz/s", at[i].DopplerRate, fdRate)
+			}
+		})
+	}
+}
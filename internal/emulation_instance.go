@@ -1,22 +1,60 @@
 package demokubenet
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"slices"
+	"sync"
 	"time"
 )
 
 const EasyEvent EventType = "EasyEvent"
 
+// EasyEvent 每一轮的处理流水线：卫星轨道外推与站点天气（含移动终端位置）更新并行，完成后计算链路，
+// 发布结果的处理器可以订阅在 PhasePublish 阶段。EasyEvent 按轮串行执行，
+// 链路计算读到的卫星和地面站状态总是来自同一轮
+const (
+	StepPropagate = "propagate-satellites"
+	StepWeather   = "update-weather"
+	StepLinks     = "compute-links"
+
+	PhaseUpdate  = 0
+	PhaseLinks   = 1
+	PhasePublish = 2
+)
+
 type EmulationInstance struct {
 	Scheduler  *EventBus
 	Satellites []*Satellite
 	Stations   []*Station
-	Links      []LinkCache
+	// 链路表只在构造时生成一次，之后原地更新，节点增删时才增删对应链路
+	Links     []LinkCache
+	linkIndex map[linkKey]int
+	// 衰减变化超过该阈值 (dB) 或网络属性变化的链路在 LinkDiff 中作为更新上报
+	AttenuationThreshold float64
+	pendingRemoved       []LinkChange
+	// 降雨率的来源，默认使用天气数据中的当前降水量
+	RainRateMode RainRateMode
+	// 星间链路的拓扑，默认不生成星间链路。ISLs 每轮按拓扑增删，Src 和 Dst 均为卫星
+	ISLConfig ISLConfig
+	ISLs      []LinkCache
+	islIndex  map[islKey]int
+
+	clock Clock
+	// 节点移除后的回调，在 RemoveNode 释放 mu 之后调用
+	nodeRemoved []func(Node)
+	// mu 保护节点列表，流水线各步骤持读锁并发执行，增加节点和外部天气持写锁。
+	// 相邻两轮由调度器串行（见 EventBus.SetSequential），各步骤另外持有自己的锁，
+	// 保护直接调用 CalculateLinks 以及读取链路表的方法；
+	// 链路计算读取所有步骤的结果，按 propagateMu、weatherMu、linkMu 的顺序加锁
+	mu          sync.RWMutex
+	propagateMu sync.Mutex
+	weatherMu   sync.Mutex
+	linkMu      sync.Mutex
 }
 
 func NewEmulationInstanceScale(stationCount, satelliteCount int) (*EmulationInstance, error) {
-
-	var errList error
 	stations, err := readStationsCount("data/terminal.txt", stationCount)
 	if err != nil {
 		log.Fatalf("读取站点失败: %v", err)
@@ -27,22 +65,10 @@ func NewEmulationInstanceScale(stationCount, satelliteCount int) (*EmulationInst
 	if err != nil {
 		log.Fatalf("读取卫星失败: %v", err)
 	}
-	// satelliteLinks := MakeSatelliteLinks()
-	sched := NewEventBus(10)
-	instance := &EmulationInstance{
-		Scheduler:  sched,
-		Satellites: satellites,
-		Stations:   stations,
-		// SatelliteLinks: satelliteLinks,
-	}
-	sched.Subscribe(EasyEvent, func(eb *EventBus, event Event) error {
-		return instance.EasyCalculateLinks(time.Now())
-	})
-	return instance, errList
+	return newEmulationInstance(stations, satellites)
 }
 
 func NewEmulationInstance() (*EmulationInstance, error) {
-	var errList error
 	stations, err := readStations("data/station_data500.txt")
 	if err != nil {
 		log.Fatalf("读取站点失败: %v", err)
@@ -53,26 +79,203 @@ func NewEmulationInstance() (*EmulationInstance, error) {
 	if err != nil {
 		log.Fatalf("读取卫星失败: %v", err)
 	}
+	return newEmulationInstance(stations, satellites)
+}
+
+func newEmulationInstance(stations []*Station, satellites []*Satellite) (*EmulationInstance, error) {
+	var errList error
 	// satelliteLinks := MakeSatelliteLinks()
 	sched := NewEventBus(10)
 	instance := &EmulationInstance{
 		Scheduler:  sched,
 		Satellites: satellites,
 		Stations:   stations,
+		Links:      MakeLinks(stations, satellites),
+
+		AttenuationThreshold: DefaultAttenuationThreshold,
+		ISLConfig:            DefaultISLConfig(),
+		islIndex:             make(map[islKey]int),
 		// SatelliteLinks: satelliteLinks,
 	}
-	sched.Subscribe(EasyEvent, func(eb *EventBus, event Event) error {
-		return instance.EasyCalculateLinks(time.Now())
+	instance.rebuildLinkIndex()
+	instance.SetClock(NewRealClock())
+	sched.SetSequential(EasyEvent, true)
+	SubscribeTypedWithOptions(sched, EasyEvent, func(eb *EventBus, event Event, payload LinkUpdatePayload) error {
+		if err := eb.Context().Err(); err != nil {
+			return err
+		}
+		instance.propagateSatellites(instance.eventTime(event), newLinkScope(payload))
+		return nil
+	}, HandlerOptions{Name: StepPropagate, Phase: PhaseUpdate})
+	SubscribeTypedWithOptions(sched, EasyEvent, func(eb *EventBus, event Event, payload LinkUpdatePayload) error {
+		if err := eb.Context().Err(); err != nil {
+			return err
+		}
+		instance.updateWeather(instance.eventTime(event), newLinkScope(payload))
+		return nil
+	}, HandlerOptions{Name: StepWeather, Phase: PhaseUpdate})
+	SubscribeTypedWithOptions(sched, EasyEvent, func(eb *EventBus, event Event, payload LinkUpdatePayload) error {
+		if err := eb.Context().Err(); err != nil {
+			return err
+		}
+		instance.publishLinkDiff(instance.computeLinks(instance.eventTime(event), newLinkScope(payload)))
+		return nil
+	}, HandlerOptions{Name: StepLinks, Phase: PhaseLinks, After: []string{StepPropagate, StepWeather}})
+	SubscribeTyped(sched, NodeAddedEvent, func(eb *EventBus, event Event, payload NodeAddedPayload) error {
+		return instance.AddNode(payload.Node)
+	})
+	SubscribeTyped(sched, NodeRemovedEvent, func(eb *EventBus, event Event, payload NodeRemovedPayload) error {
+		return instance.RemoveNode(payload.Node)
+	})
+	SubscribeTyped(sched, WeatherChangedEvent, func(eb *EventBus, event Event, payload WeatherChangedPayload) error {
+		return instance.SetWeather(payload.Station, payload.Weather)
 	})
 	return instance, errList
 }
 
+// AddNode 加入新的卫星或地面站，并在链路表末尾追加它与所有对端的链路，
+// 链路属性在下一轮计算时更新
+func (e *EmulationInstance) AddNode(node Node) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch n := node.(type) {
+	case *Satellite:
+		if slices.Contains(e.Satellites, n) {
+			return fmt.Errorf("satellite already added")
+		}
+		e.Satellites = append(e.Satellites, n)
+		for _, st := range e.Stations {
+			e.appendLink(n, st)
+		}
+	case *Station:
+		if slices.Contains(e.Stations, n) {
+			return fmt.Errorf("station already added")
+		}
+		e.Stations = append(e.Stations, n)
+		for _, sat := range e.Satellites {
+			e.appendLink(sat, n)
+		}
+	default:
+		return fmt.Errorf("unsupported node type %T", node)
+	}
+	return nil
+}
+
+// LoadMobileStations 读取移动终端的轨迹文件并加入实例，轨迹从时钟的当前时刻出发，
+// 使用仿真时钟时应在 SetClock 之后调用
+func (e *EmulationInstance) LoadMobileStations(filename string) ([]*Station, error) {
+	stations, err := ReadMobileStations(filename, e.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range stations {
+		if err := e.AddNode(st); err != nil {
+			return nil, err
+		}
+	}
+	return stations, nil
+}
+
+// OnNodeRemoved 注册节点移除后的回调，例如释放节点占用的 Pod ID
+func (e *EmulationInstance) OnNodeRemoved(f func(Node)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.nodeRemoved = append(e.nodeRemoved, f)
+}
+
+// RemoveNode 移除卫星或地面站及其所有链路，成功后调用 OnNodeRemoved 注册的回调
+func (e *EmulationInstance) RemoveNode(node Node) error {
+	hooks, err := e.removeNode(node)
+	if err != nil {
+		return err
+	}
+	for _, f := range hooks {
+		f(node)
+	}
+	return nil
+}
+
+func (e *EmulationInstance) removeNode(node Node) ([]func(Node), error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch n := node.(type) {
+	case *Satellite:
+		i := slices.Index(e.Satellites, n)
+		if i < 0 {
+			return nil, fmt.Errorf("satellite not found")
+		}
+		e.Satellites = slices.Delete(e.Satellites, i, i+1)
+		e.removeLinksWhere(func(k linkKey) bool { return k.sat == n })
+		e.removeISLsWhere(func(link *LinkCache) bool { return link.SrcNode == n || link.DstNode == n })
+	case *Station:
+		i := slices.Index(e.Stations, n)
+		if i < 0 {
+			return nil, fmt.Errorf("station not found")
+		}
+		e.Stations = slices.Delete(e.Stations, i, i+1)
+		e.removeLinksWhere(func(k linkKey) bool { return k.station == n })
+	default:
+		return nil, fmt.Errorf("unsupported node type %T", node)
+	}
+	return slices.Clone(e.nodeRemoved), nil
+}
+
+// SetWeather 使用外部提供的天气数据替代站点的模拟天气
+func (e *EmulationInstance) SetWeather(station *Station, weather EnvironmentIndex) error {
+	if station == nil {
+		return fmt.Errorf("weather changed event without station")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	station.WeatherIdx = weather
+	station.externalWeather = true
+	return nil
+}
+
+// SetClock 替换实例及其调度器使用的时钟，需在 Start 之前调用
+func (e *EmulationInstance) SetClock(c Clock) {
+	e.clock = c
+	e.Scheduler.SetClock(c)
+}
+
+func (e *EmulationInstance) Clock() Clock {
+	return e.clock
+}
+
+// NewTickEvent 推进时钟并返回携带本轮仿真时刻的事件
+func (e *EmulationInstance) NewTickEvent(t EventType) Event {
+	return Event{Type: t, Time: e.clock.Tick()}
+}
+
+// ScheduleLinkUpdates 每隔 interval 仿真时间重新计算一次链路，共 rounds 次，
+// rounds <= 0 表示一直运行。wg 不为 nil 时每轮完成后调用一次 wg.Done()
+func (e *EmulationInstance) ScheduleLinkUpdates(interval time.Duration, rounds int, wg *sync.WaitGroup) (TimerID, error) {
+	return e.Scheduler.ScheduleEvery(Event{Type: EasyEvent}, e.clock.Now(), interval, rounds, wg)
+}
+
+func (e *EmulationInstance) eventTime(event Event) time.Time {
+	if event.Time.IsZero() {
+		return e.clock.Now()
+	}
+	return event.Time
+}
+
 func (e *EmulationInstance) Start() {
 	log.Println("emulation_instance.Start")
 	// Start the scheduler
 	e.Scheduler.Start()
 }
 
+// Stop 停止调度器，等待进行中的链路计算完成，详见 EventBus.Stop
+func (e *EmulationInstance) Stop(ctx context.Context) error {
+	log.Println("emulation_instance.Stop")
+	return e.Scheduler.Stop(ctx)
+}
+
+func (e *EmulationInstance) Close() error {
+	return e.Stop(context.Background())
+}
+
 // func (e *EmulationInstance) GetSatelliteLinks() []LinkCache {
 // 	fmt.Println("Emulation instance: GetSatelliteLinks")
 // 	return e.SatelliteLinks
@@ -81,7 +284,7 @@ func (e *EmulationInstance) Start() {
 func MakeLinks(stations []*Station, satellites []*Satellite) []LinkCache {
 	log.Println("MakeLinks...")
 	startTime := time.Now()
-	var links []LinkCache
+	links := make([]LinkCache, 0, len(stations)*len(satellites))
 	for _, station := range stations {
 		for _, sat := range satellites {
 			link := LinkCache{
@@ -97,14 +300,70 @@ func MakeLinks(stations []*Station, satellites []*Satellite) []LinkCache {
 	return links
 }
 
-func updateSatellitePositions(satellites []*Satellite, timestamp time.Time) {
+// linkScope 一轮链路计算涉及的节点集合，nil 表示不限
+type linkScope struct {
+	stations   map[*Station]bool
+	satellites map[*Satellite]bool
+	frequency  float64
+	rainMode   RainRateMode
+	// 本轮链路计算的时刻
+	time time.Time
+}
+
+func newLinkScope(p LinkUpdatePayload) linkScope {
+	scope := linkScope{frequency: p.Frequency}
+	if scope.frequency == 0 {
+		scope.frequency = DefaultFrequency
+	}
+	if len(p.Stations) > 0 {
+		scope.stations = make(map[*Station]bool, len(p.Stations))
+		for _, st := range p.Stations {
+			scope.stations[st] = true
+		}
+	}
+	if len(p.Satellites) > 0 {
+		scope.satellites = make(map[*Satellite]bool, len(p.Satellites))
+		for _, sat := range p.Satellites {
+			scope.satellites[sat] = true
+		}
+	}
+	return scope
+}
+
+func (s linkScope) hasStation(st *Station) bool {
+	return s.stations == nil || s.stations[st]
+}
+
+func (s linkScope) hasSatellite(sat *Satellite) bool {
+	return s.satellites == nil || s.satellites[sat]
+}
+
+// hasLink 链路的两端都在范围内
+func (s linkScope) hasLink(link *LinkCache) bool {
+	return s.hasNode(link.SrcNode) && s.hasNode(link.DstNode)
+}
+
+func (s linkScope) hasNode(node Node) bool {
+	switch n := node.(type) {
+	case *Satellite:
+		return s.hasSatellite(n)
+	case *Station:
+		return s.hasStation(n)
+	}
+	return false
+}
+
+func updateSatellitePositions(satellites []*Satellite, timestamp time.Time, scope linkScope) {
 	log.Println("updateSatellitePositions...")
 	startTime := time.Now()
 	cnt := 0
 	for i := range satellites {
 		satellite := satellites[i]
+		if !scope.hasSatellite(satellite) {
+			continue
+		}
 		// satellite.GetPosition(timestamp)
-		satellite.Position = satellite.GetPosition(timestamp)
+		satellite.updateState(timestamp)
 		cnt++
 	}
 	endTime := time.Now()
@@ -112,28 +371,53 @@ func updateSatellitePositions(satellites []*Satellite, timestamp time.Time) {
 	log.Printf("updateSatellitePositions took %v", endTime.Sub(startTime))
 }
 
-func updateStationPositions(stations []*Station, timestamp time.Time) {
+// updateStationPositions 更新移动终端的位置，固定地面站不变
+func updateStationPositions(stations []*Station, timestamp time.Time, scope linkScope) {
 	log.Printf("updateStationPositions...")
 	startTime := time.Now()
+	count := 0
 	for i := range stations {
 		station := stations[i]
+		if station.Trajectory == nil || !scope.hasStation(station) {
+			continue
+		}
 		station.position = station.GetPosition(timestamp)
+		count++
 	}
 	endTime := time.Now()
+	log.Printf("updateStationPositions count: %d", count)
 	log.Printf("updateStationPositions took %v", endTime.Sub(startTime))
 }
 
+// missingRainMaps 没有读入 P.837 地图的警告只打印一次
+var missingRainMaps sync.Once
+
+// updateRainRates 在位置变化后重新计算站点的 R0.01，链路步骤只读取结果。
+// 没有读入 P.837 地图时 R0.01 不可用，链路步骤改用天气数据中的当前降水量
+func updateRainRates(stations []*Station, scope linkScope) {
+	for _, station := range stations {
+		if scope.hasStation(station) && !station.updateRainRate001() {
+			missingRainMaps.Do(func() {
+				log.Println("RainRateStatistical: P.837 maps not loaded (itur.LoadMonthlyRainGrids), using instantaneous precipitation")
+			})
+		}
+	}
+}
+
 func getWeatherBasedOnTerminal(terminal *Station, timestamp time.Time) EnvironmentIndex {
 
 	return EnvironmentIndex{Temperature2m: 10.0, Precipitation: 0.0, Pressure: 1010.0} // 模拟返回一些天气数据
 }
 
-func updateEnvironmentIndex(stations []*Station, timestamp time.Time) {
+func updateEnvironmentIndex(stations []*Station, timestamp time.Time, scope linkScope) {
 	log.Println("updateEnvironmentIndex...")
 	startTime := time.Now()
 	count := 0
 	for i := range stations {
 		station := stations[i]
+		if station.externalWeather || !scope.hasStation(station) {
+			continue
+		}
 		station.WeatherIdx = getWeatherBasedOnTerminal(station, timestamp)
 		count++
 	}
@@ -153,7 +437,7 @@ func updateEnvironmentIndex(stations []*Station, timestamp time.Time) {
 	log.Printf("updateEnvironmentIndex took %v", time.Since(startTime))
 }
 
-func updateLinkProperties(links []LinkCache) {
+func updateLinkProperties(links []LinkCache, scope linkScope) {
 	log.Println("updateLinkProperties...")
 	startTime := time.Now()
 	count := 0
@@ -169,31 +453,141 @@ func updateLinkProperties(links []LinkCache) {
 			log.Printf("Error: DstNode is not a Station")
 			continue
 		}
+		if !scope.hasSatellite(sat) || !scope.hasStation(dst) {
+			continue
+		}
 		srcPos := sat.Position
-		dstPos := dst.position
+		// 移动终端的位置和速度按本轮时刻计算，与卫星的位置来自同一轮
+		dstPos, dstVel := dst.stateAt(scope.time)
+		link.Elevation, link.Azimuth, link.Range = lookAngles(dstPos, srcPos)
+		link.Active = link.Elevation >= dst.MinElevation
+		// 未配置或只配置了部分收发参数的节点使用默认值
+		tx, rx := sat.Transmitter.withDefaults(), dst.Receiver.withDefaults()
+		if !link.Active {
+			link.Ar = 0
+			link.RangeRate, link.Doppler, link.DopplerRate = 0, 0, 0
+			updateNetworkProperties(link, tx.Bandwidth)
+			continue
+		}
 		count++
-		link.Ar = CalculateSatelliteLink(link, srcPos, dstPos, dst.WeatherIdx.Precipitation)
+		radio := linkRadio(link, dst, tx, scope)
+		R001, ok := dst.RainRate001()
+		mode := scope.rainMode
+		if !ok {
+			mode = RainRateInstantaneous
+		}
+		if mode == RainRateInstantaneous {
+			R001 = dst.WeatherIdx.Precipitation
+		}
+		link.Ar = CalculateSatelliteLink(link, dstPos, dst.WeatherIdx, R001, radio, mode)
+		link.Budget = CalculateLinkBudget(tx, rx, link.Range, radio.Frequency, link.Ar)
+		updateNetworkProperties(link, tx.Bandwidth)
+		updateDoppler(link, ecefState{R: LLAtoECEF(dstPos), V: [3]float64{dstVel.X, dstVel.Y, dstVel.Z}}, sat.state, radio.Frequency)
 
 	}
-	log.Printf("updateLinkProperties count: %d", count)
+	log.Printf("updateLinkProperties active count: %d", count)
 	log.Printf("updateLinkProperties took %v", time.Since(startTime))
 }
 
+// linkRadio 确定链路使用的无线参数：链路单独的配置优先于地面站的配置，
+// 未指定的频率依次取卫星发射机和本轮事件中的频率
+func linkRadio(link *LinkCache, st *Station, tx Transmitter, scope linkScope) RadioConfig {
+	radio := st.Radio
+	if link.Radio != nil {
+		radio = *link.Radio
+	} else if radio == (RadioConfig{}) {
+		radio = DefaultRadioConfig()
+	}
+	if radio.Frequency == 0 {
+		radio.Frequency = tx.Frequency
+	}
+	if radio.Frequency == 0 {
+		radio.Frequency = scope.frequency
+	}
+	if radio.TimePercentage == 0 {
+		radio.TimePercentage = DefaultTimePercentage
+	}
+	return radio
+}
+
+// ActiveLinks 返回当前可见（仰角不低于地面站掩模）的链路副本
+func (e *EmulationInstance) ActiveLinks() []LinkCache {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	e.linkMu.Lock()
+	defer e.linkMu.Unlock()
+	var active []LinkCache
+	for i := range e.Links {
+		if e.Links[i].Active {
+			active = append(active, e.Links[i])
+		}
+	}
+	return active
+}
+
 func (e *EmulationInstance) EasyCalculateLinks(timestamp time.Time) error {
+	return e.CalculateLinks(timestamp, LinkUpdatePayload{})
+}
+
+// CalculateLinks 依次执行流水线的各个步骤，计算 timestamp 时刻 payload 范围内的链路属性，
+// 范围外的链路保持上一轮的结果。不经过调度器，不要与调度器中的 EasyEvent 同时使用
+func (e *EmulationInstance) CalculateLinks(timestamp time.Time, payload LinkUpdatePayload) error {
 	// log.Println("instance: EasyCalculateLinks")
+	scope := newLinkScope(payload)
+	e.propagateSatellites(timestamp, scope)
+	e.updateWeather(timestamp, scope)
+	e.publishLinkDiff(e.computeLinks(timestamp, scope))
+	return nil
+}
+
+func (e *EmulationInstance) propagateSatellites(timestamp time.Time, scope linkScope) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	e.propagateMu.Lock()
+	defer e.propagateMu.Unlock()
+	updateSatellitePositions(e.Satellites, timestamp, scope)
+}
 
-	updateSatellitePositions(e.Satellites, timestamp)
-	// updateStationPositions(e.Stations, timestamp)
-	e.Links = MakeLinks(e.Stations, e.Satellites)
+func (e *EmulationInstance) updateWeather(timestamp time.Time, scope linkScope) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	e.weatherMu.Lock()
+	defer e.weatherMu.Unlock()
 	// updateEnvironmentIndex(e.Links, timestamp)
-	updateEnvironmentIndex(e.Stations, timestamp)
-	updateLinkProperties(e.Links)
+	// 天气与位置有关，先更新移动终端的位置
+	updateStationPositions(e.Stations, timestamp, scope)
+	updateEnvironmentIndex(e.Stations, timestamp, scope)
+	if e.RainRateMode == RainRateStatistical {
+		updateRainRates(e.Stations, scope)
+	}
+}
+
+// computeLinks 更新链路属性并返回与上一轮相比的变化
+func (e *EmulationInstance) computeLinks(timestamp time.Time, scope linkScope) LinkDiff {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	e.propagateMu.Lock()
+	defer e.propagateMu.Unlock()
+	e.weatherMu.Lock()
+	defer e.weatherMu.Unlock()
+	e.linkMu.Lock()
+	defer e.linkMu.Unlock()
+
+	scope.rainMode = e.RainRateMode
+	scope.time = timestamp
+	updateLinkProperties(e.Links, scope)
+	e.updateISLs(scope)
+
+	diff := LinkDiff{Time: timestamp, Removed: e.pendingRemoved}
+	e.pendingRemoved = nil
+	diffLinks(e.Links, scope, e.AttenuationThreshold, &diff)
+	diffLinks(e.ISLs, scope, e.AttenuationThreshold, &diff)
+	e.pruneISLs()
 
 	log.Println("links count: ", len(e.Links))
+	log.Printf("link diff: %d added, %d removed, %d updated", len(diff.Added), len(diff.Removed), len(diff.Updated))
 	// log.Println("Satellite size:", unsafe.Sizeof(Satellite{}))
 	// log.Println("Station size:", unsafe.Sizeof(Station{}))
 	// log.Println("LinkCache size:", unsafe.Sizeof(LinkCache{}))
-
-	return nil
-
+	return diff
 }
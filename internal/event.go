@@ -1,11 +1,75 @@
 package demokubenet
 
+import (
+	"fmt"
+	"time"
+)
+
 type EventType = string
 
+const (
+	NodeAddedEvent      EventType = "NodeAdded"
+	NodeRemovedEvent    EventType = "NodeRemoved"
+	WeatherChangedEvent EventType = "WeatherChanged"
+)
+
 type Event struct {
 	Type EventType
+	// 本次事件对应的仿真时刻，为零值时由处理器使用实例时钟的当前时刻
+	Time time.Time
+	// 事件负载，具体类型由事件类型决定，见下面的 *Payload
+	Payload any
+}
+
+// LinkUpdatePayload EasyEvent 的负载，限定本轮链路计算的范围
+type LinkUpdatePayload struct {
+	// 只更新这些节点相关的链路，为空表示全部
+	Stations   []*Station
+	Satellites []*Satellite
+	// 载波频率 (GHz)，为 0 时使用 DefaultFrequency
+	Frequency float64
+}
+
+// NodeAddedPayload NodeAddedEvent 的负载，Node 为 *Satellite 或 *Station
+type NodeAddedPayload struct {
+	Node Node
+}
+
+// NodeRemovedPayload NodeRemovedEvent 的负载
+type NodeRemovedPayload struct {
+	Node Node
+}
+
+// WeatherChangedPayload WeatherChangedEvent 的负载
+type WeatherChangedPayload struct {
+	Station *Station
+	Weather EnvironmentIndex
 }
 
 // 处理器函数类型
 // 携带调度器信息，比如执行时间等
 type EventHandler func(*EventBus, Event) error
+
+// TypedEventHandler 直接接收解析后负载的处理器
+type TypedEventHandler[T any] func(*EventBus, Event, T) error
+
+// SubscribeTyped 订阅负载类型为 T 的事件，Payload 为 nil 时传入 T 的零值，
+// 类型不符时处理器返回错误
+func SubscribeTyped[T any](eb *EventBus, eventType EventType, h TypedEventHandler[T]) SubscriptionID {
+	return SubscribeTypedWithOptions(eb, eventType, h, HandlerOptions{})
+}
+
+// SubscribeTypedWithOptions 带执行顺序的 SubscribeTyped，见 HandlerOptions
+func SubscribeTypedWithOptions[T any](eb *EventBus, eventType EventType, h TypedEventHandler[T], opts HandlerOptions) SubscriptionID {
+	return eb.SubscribeWithOptions(eventType, func(eb *EventBus, evt Event) error {
+		var payload T
+		if evt.Payload != nil {
+			p, ok := evt.Payload.(T)
+			if !ok {
+				return fmt.Errorf("event %s: unexpected payload type %T", evt.Type, evt.Payload)
+			}
+			payload = p
+		}
+		return h(eb, evt, payload)
+	}, opts)
+}
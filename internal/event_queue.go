@@ -0,0 +1,179 @@
+package demokubenet
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// ErrEventDropped 事件因队列已满被丢弃
+var ErrEventDropped = errors.New("event dropped: queue full")
+
+// QueuePolicy 事件队列已满时的处理策略
+type QueuePolicy int
+
+const (
+	// PolicyBlock 发布方阻塞直到队列有空位
+	PolicyBlock QueuePolicy = iota
+	// PolicyDropOldest 丢弃队首最旧的事件，为新事件腾出位置
+	PolicyDropOldest
+	// PolicyDropNewest 丢弃新发布的事件
+	PolicyDropNewest
+	// PolicyCoalesce 队列中已有类型和负载都相同的事件时用新事件替换它，等待方一起得到新事件的结果，
+	// 适用于定时触发的 EasyEvent 这类只关心最新一轮的事件。负载不同的事件（如不同节点的
+	// NodeAddedEvent）不会合并，负载不可比较时也不合并；没有可合并的事件且队列已满时阻塞
+	PolicyCoalesce
+)
+
+// QueueStats 事件队列的计数，用于观察处理是否跟得上发布
+type QueueStats struct {
+	Depth      int    // 当前排队的事件数
+	MaxDepth   int    // 排队事件数的最大值
+	Enqueued   uint64 // 成功入队的事件数（含合并）
+	Dispatched uint64 // 已取出执行的事件数
+	Dropped    uint64 // 因队列已满被丢弃的事件数
+	Coalesced  uint64 // 被同类型新事件替换的事件数
+	Blocked    uint64 // 因队列已满需要等待的发布次数
+}
+
+// eventQueue 有界的事件队列，按 policy 处理队列已满的情况
+type eventQueue struct {
+	mu      sync.Mutex
+	notFull *sync.Cond
+	items   []EventWrapper
+	size    int
+	policy  QueuePolicy
+	closed  bool
+	stats   QueueStats
+}
+
+func newEventQueue(size int, policy QueuePolicy) *eventQueue {
+	if size < 1 {
+		size = 1
+	}
+	q := &eventQueue{
+		items:  make([]EventWrapper, 0, size),
+		size:   size,
+		policy: policy,
+	}
+	q.notFull = sync.NewCond(&q.mu)
+	return q
+}
+
+// push 放入事件。返回的 dropped 为被挤出队列的旧事件，err 非空时 w 本身未入队，
+// 两者都由调用方在锁外结束。block 为 false 时队列已满且策略为阻塞的情况直接丢弃 w
+func (q *eventQueue) push(w EventWrapper, block bool) (dropped *EventWrapper, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrBusClosed
+	}
+
+	if q.policy == PolicyCoalesce {
+		for i := range q.items {
+			old := q.items[i]
+			if old.Event.Type != w.Event.Type || !samePayload(old.Event.Payload, w.Event.Payload) {
+				continue
+			}
+			w.merged = append(old.merged, old)
+			w.merged[len(w.merged)-1].merged = nil
+			q.items[i] = w
+			q.stats.Enqueued++
+			q.stats.Coalesced++
+			return nil, nil
+		}
+	}
+
+	if len(q.items) >= q.size {
+		switch q.policy {
+		case PolicyDropOldest:
+			old := q.items[0]
+			q.items = append(q.items[:0], q.items[1:]...)
+			q.stats.Dropped++
+			dropped = &old
+		case PolicyDropNewest:
+			q.stats.Dropped++
+			return nil, ErrEventDropped
+		default:
+			if !block {
+				q.stats.Dropped++
+				return nil, ErrEventDropped
+			}
+			q.stats.Blocked++
+			for len(q.items) >= q.size && !q.closed {
+				q.notFull.Wait()
+			}
+			if q.closed {
+				return nil, ErrBusClosed
+			}
+		}
+	}
+
+	q.items = append(q.items, w)
+	q.stats.Enqueued++
+	if len(q.items) > q.stats.MaxDepth {
+		q.stats.MaxDepth = len(q.items)
+	}
+	return dropped, nil
+}
+
+// samePayload 两个负载是否可以合并：都为 nil，或类型相同、可比较且相等。
+// 指针按地址比较，不同节点的负载即使内容相同也不合并
+func samePayload(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() || !va.Comparable() || !vb.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// pop 取出队首事件，队列为空时返回 false
+func (q *eventQueue) pop() (EventWrapper, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return EventWrapper{}, false
+	}
+	w := q.items[0]
+	q.items[0] = EventWrapper{}
+	q.items = q.items[1:]
+	q.stats.Dispatched++
+	q.notFull.Signal()
+	return w, true
+}
+
+// close 拒绝之后的发布并唤醒所有阻塞的发布方，已排队的事件仍可取出
+func (q *eventQueue) close() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	q.closed = true
+	q.notFull.Broadcast()
+	return true
+}
+
+func (q *eventQueue) reopen() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = false
+}
+
+func (q *eventQueue) setPolicy(policy QueuePolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.policy = policy
+	q.notFull.Broadcast()
+}
+
+func (q *eventQueue) snapshot() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.Depth = len(q.items)
+	return stats
+}
@@ -0,0 +1,203 @@
+package demokubenet
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func queuedPayloads(q *eventQueue) []any {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var payloads []any
+	for _, w := range q.items {
+		payloads = append(payloads, w.Event.Payload)
+	}
+	return payloads
+}
+
+func sameSlice(a, b []any) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEventQueuePolicies(t *testing.T) {
+	cases := []struct {
+		policy  QueuePolicy
+		push    []any
+		want    []any
+		errs    []error
+		dropped []any
+		stats   QueueStats
+	}{
+		{
+			policy:  PolicyDropOldest,
+			push:    []any{1, 2, 3},
+			want:    []any{2, 3},
+			errs:    []error{nil, nil, nil},
+			dropped: []any{1},
+			stats:   QueueStats{Depth: 2, MaxDepth: 2, Enqueued: 3, Dropped: 1},
+		},
+		{
+			policy: PolicyDropNewest,
+			push:   []any{1, 2, 3},
+			want:   []any{1, 2},
+			errs:   []error{nil, nil, ErrEventDropped},
+			stats:  QueueStats{Depth: 2, MaxDepth: 2, Enqueued: 2, Dropped: 1},
+		},
+		{
+			// 相等的负载合并到原位置，不同或不可比较的负载不合并
+			policy: PolicyCoalesce,
+			push:   []any{1, 1, 2, 1},
+			want:   []any{1, 2},
+			errs:   []error{nil, nil, nil, nil},
+			stats:  QueueStats{Depth: 2, MaxDepth: 2, Enqueued: 4, Coalesced: 2},
+		},
+		{
+			// 非阻塞发布时阻塞策略直接丢弃
+			policy: PolicyBlock,
+			push:   []any{1, 2, 3},
+			want:   []any{1, 2},
+			errs:   []error{nil, nil, ErrEventDropped},
+			stats:  QueueStats{Depth: 2, MaxDepth: 2, Enqueued: 2, Dropped: 1},
+		},
+	}
+	for _, c := range cases {
+		q := newEventQueue(2, c.policy)
+		var dropped []any
+		for i, p := range c.push {
+			d, err := q.push(EventWrapper{Event: Event{Type: "tick", Payload: p}}, false)
+			if !errors.Is(err, c.errs[i]) {
+				t.Errorf("policy %d push %d: error %v, want %v", c.policy, i, err, c.errs[i])
+			}
+			if d != nil {
+				dropped = append(dropped, d.Event.Payload)
+			}
+		}
+		if got := queuedPayloads(q); !sameSlice(got, c.want) {
+			t.Errorf("policy %d: queued %v, want %v", c.policy, got, c.want)
+		}
+		if !sameSlice(dropped, c.dropped) {
+			t.Errorf("policy %d: dropped %v, want %v", c.policy, dropped, c.dropped)
+		}
+		if got := q.snapshot(); got != c.stats {
+			t.Errorf("policy %d: stats %+v, want %+v", c.policy, got, c.stats)
+		}
+	}
+}
+
+func TestEventQueueBlock(t *testing.T) {
+	q := newEventQueue(1, PolicyBlock)
+	if _, err := q.push(EventWrapper{Event: Event{Type: "tick", Payload: 1}}, true); err != nil {
+		t.Fatal(err)
+	}
+	pushed := make(chan error)
+	go func() {
+		_, err := q.push(EventWrapper{Event: Event{Type: "tick", Payload: 2}}, true)
+		pushed <- err
+	}()
+	select {
+	case err := <-pushed:
+		t.Fatalf("push returned %v on a full queue", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if w, ok := q.pop(); !ok || w.Event.Payload != 1 {
+		t.Fatalf("pop() = %v, %v", w.Event.Payload, ok)
+	}
+	if err := <-pushed; err != nil {
+		t.Fatal(err)
+	}
+
+	// 关闭时唤醒阻塞的发布方
+	go func() {
+		_, err := q.push(EventWrapper{Event: Event{Type: "tick", Payload: 3}}, true)
+		pushed <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	q.close()
+	if err := <-pushed; !errors.Is(err, ErrBusClosed) {
+		t.Errorf("blocked push after close: %v, want ErrBusClosed", err)
+	}
+	if stats := q.snapshot(); stats.Blocked != 2 || stats.Dispatched != 1 {
+		t.Errorf("stats %+v, want Blocked 2 Dispatched 1", stats)
+	}
+}
+
+func TestCoalescePayloads(t *testing.T) {
+	station := &Station{}
+	for _, c := range []struct {
+		a, b any
+		want bool
+	}{
+		{nil, nil, true},
+		{1, 1, true},
+		{1, 2, false},
+		{1, nil, false},
+		{int32(1), int64(1), false},
+		{NodeAddedPayload{Node: station}, NodeAddedPayload{Node: station}, true},
+		{NodeAddedPayload{Node: station}, NodeAddedPayload{Node: &Station{}}, false},
+		// 不可比较的负载不合并，也不会 panic
+		{[]int{1}, []int{1}, false},
+		{LinkUpdatePayload{}, LinkUpdatePayload{}, false},
+	} {
+		if got := samePayload(c.a, c.b); got != c.want {
+			t.Errorf("samePayload(%v, %v) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestCoalescedFuturesShareResult(t *testing.T) {
+	eb := NewEventBusWithPolicy(2, PolicyCoalesce)
+	eb.SetClock(NewSimulatedClock(clockStart, time.Second))
+	// 同步执行的定时事件阻塞调度协程，之后发布的事件留在队列里
+	started, release := make(chan struct{}), make(chan struct{})
+	eb.Subscribe("hold", func(*EventBus, Event) error {
+		close(started)
+		<-release
+		return nil
+	})
+	errRound := errors.New("round failed")
+	var rec recorder
+	eb.Subscribe("round", func(eb *EventBus, evt Event) error {
+		rec.handle(eb, evt)
+		return errRound
+	})
+	startBus(t, eb)
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	t.Cleanup(unblock)
+	eb.ScheduleAt(Event{Type: "hold"}, clockStart, nil)
+	<-started
+
+	var futures []*Future
+	for i := 0; i < 3; i++ {
+		futures = append(futures, eb.Publish(Event{Type: "round"}))
+	}
+	other := eb.Publish(Event{Type: "round", Payload: 1})
+	// 队列已满，TryPublish 不等待
+	full := eb.TryPublish(Event{Type: "round", Payload: 2})
+	if err := waitFuture(t, full); !errors.Is(err, ErrEventDropped) {
+		t.Errorf("TryPublish on full queue: %v, want ErrEventDropped", err)
+	}
+	unblock()
+
+	for i, f := range append(futures, other) {
+		if err := waitFuture(t, f); !errors.Is(err, errRound) {
+			t.Errorf("future %d: %v, want %v", i, err, errRound)
+		}
+	}
+	if got := len(rec.snapshot()); got != 2 {
+		t.Errorf("handler ran %d times, want 2", got)
+	}
+	if stats := eb.Stats(); stats.Coalesced != 2 || stats.Dropped != 1 {
+		t.Errorf("stats %+v, want Coalesced 2 Dropped 1", stats)
+	}
+}
@@ -0,0 +1,54 @@
+package demokubenet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubscribeTyped(t *testing.T) {
+	eb := NewEventBus(10)
+	var got []NodeAddedPayload
+	SubscribeTyped(eb, NodeAddedEvent, func(_ *EventBus, _ Event, p NodeAddedPayload) error {
+		got = append(got, p)
+		return nil
+	})
+	var freqs []float64
+	SubscribeTyped(eb, "EasyEvent", func(_ *EventBus, _ Event, p *LinkUpdatePayload) error {
+		if p == nil {
+			freqs = append(freqs, -1)
+		} else {
+			freqs = append(freqs, p.Frequency)
+		}
+		return nil
+	})
+	startBus(t, eb)
+
+	station := &Station{MinElevation: 10}
+	for _, c := range []struct {
+		evt     Event
+		wantErr string
+	}{
+		{evt: Event{Type: NodeAddedEvent, Payload: NodeAddedPayload{Node: station}}},
+		// 没有负载时处理器收到零值
+		{evt: Event{Type: NodeAddedEvent}},
+		{evt: Event{Type: NodeAddedEvent, Payload: &NodeAddedPayload{Node: station}}, wantErr: "unexpected payload type *demokubenet.NodeAddedPayload"},
+		{evt: Event{Type: "EasyEvent", Payload: &LinkUpdatePayload{Frequency: 20}}},
+		{evt: Event{Type: "EasyEvent"}},
+		{evt: Event{Type: "EasyEvent", Payload: LinkUpdatePayload{}}, wantErr: "unexpected payload type demokubenet.LinkUpdatePayload"},
+	} {
+		err := waitFuture(t, eb.Publish(c.evt))
+		switch {
+		case c.wantErr == "" && err != nil:
+			t.Errorf("%s %T: %v", c.evt.Type, c.evt.Payload, err)
+		case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+			t.Errorf("%s %T: error %v, want %q", c.evt.Type, c.evt.Payload, err, c.wantErr)
+		}
+	}
+
+	if len(got) != 2 || got[0].Node != Node(station) || got[1].Node != nil {
+		t.Errorf("NodeAdded payloads = %+v", got)
+	}
+	if len(freqs) != 2 || freqs[0] != 20 || freqs[1] != -1 {
+		t.Errorf("EasyEvent frequencies = %v, want [20 -1]", freqs)
+	}
+}
@@ -0,0 +1,56 @@
+package demokubenet
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoHandlers 事件类型没有任何订阅者
+var ErrNoHandlers = errors.New("no handlers subscribed")
+
+// Future 保存一次发布的处理结果，所有处理器完成后 Done 被关闭，
+// Err 为全部处理器错误的合并（errors.Join）
+type Future struct {
+	Event Event
+	done  chan struct{}
+	err   error
+}
+
+func newFuture(evt Event) *Future {
+	return &Future{Event: evt, done: make(chan struct{})}
+}
+
+func (f *Future) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 阻塞直到事件处理完成，返回处理器错误
+func (f *Future) Wait() error {
+	<-f.done
+	return f.err
+}
+
+// WaitContext 与 Wait 相同，但 ctx 结束时提前返回 ctx.Err()
+func (f *Future) WaitContext(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Err 事件尚未处理完成时返回 nil
+func (f *Future) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
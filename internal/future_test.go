@@ -0,0 +1,106 @@
+package demokubenet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFutureErrors(t *testing.T) {
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	eb := NewEventBus(10)
+	eb.Subscribe("ok", func(*EventBus, Event) error { return nil })
+	eb.Subscribe("fail", func(*EventBus, Event) error { return errA })
+	eb.Subscribe("fail", func(*EventBus, Event) error { return nil })
+	eb.Subscribe("fail", func(*EventBus, Event) error { return errB })
+
+	var mu sync.Mutex
+	var handled, dead []error
+	eb.SetErrorHandler(func(_ Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, err)
+	})
+	eb.SetDeadLetterHandler(func(_ Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dead = append(dead, err)
+	})
+	startBus(t, eb)
+
+	if err := waitFuture(t, eb.Publish(Event{Type: "ok"})); err != nil {
+		t.Errorf("ok: %v", err)
+	}
+
+	// 所有处理器的错误合并到 Future 中
+	f := eb.Publish(Event{Type: "fail"})
+	err := waitFuture(t, f)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("fail: %v, want both handler errors", err)
+	}
+	if f.Err() != err {
+		t.Errorf("Err() = %v after completion, want %v", f.Err(), err)
+	}
+
+	err = waitFuture(t, eb.Publish(Event{Type: "nobody"}))
+	if !errors.Is(err, ErrNoHandlers) {
+		t.Errorf("nobody: %v, want ErrNoHandlers", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handled) != 2 {
+		t.Errorf("error handler called %d times, want 2", len(handled))
+	}
+	if len(dead) != 2 || !errors.Is(dead[0], errA) || !errors.Is(dead[1], ErrNoHandlers) {
+		t.Errorf("dead letters = %v", dead)
+	}
+}
+
+func TestFutureWaitContext(t *testing.T) {
+	eb := NewEventBus(10)
+	release := make(chan struct{})
+	eb.Subscribe("slow", func(*EventBus, Event) error {
+		<-release
+		return nil
+	})
+	startBus(t, eb)
+
+	f := eb.Publish(Event{Type: "slow"})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := f.WaitContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitContext() = %v, want DeadlineExceeded", err)
+	}
+	if f.Err() != nil {
+		t.Errorf("Err() = %v before completion, want nil", f.Err())
+	}
+	select {
+	case <-f.Done():
+		t.Fatal("Done closed before the handler returned")
+	default:
+	}
+	close(release)
+	if err := waitFuture(t, f); err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+}
+
+func TestPublishWithWaitDone(t *testing.T) {
+	eb := NewEventBus(10)
+	eb.Subscribe("fail", func(*EventBus, Event) error { return errors.New("boom") })
+	startBus(t, eb)
+
+	// 处理失败或无人订阅时 wg 也会被释放
+	var wg sync.WaitGroup
+	wg.Add(2)
+	if err := eb.PublishWithWait(Event{Type: "fail"}, &wg); err != nil {
+		t.Fatal(err)
+	}
+	if err := eb.PublishWithWait(Event{Type: "nobody"}, &wg); err != nil {
+		t.Fatal(err)
+	}
+	waitTimeout(t, &wg, 5*time.Second)
+}
@@ -0,0 +1,25 @@
+package demokubenet
+
+import "math"
+
+// lookAngles 计算从地面站看卫星的仰角、方位角（度）和斜距（米），
+// 卫星和地面站的位置都先转换到 ECEF，再投影到地面站的本地东北天坐标系
+func lookAngles(station, sat Position) (el, az, rangeM float64) {
+	st := LLAtoECEF(station)
+	s := LLAtoECEF(sat)
+	dx, dy, dz := s[0]-st[0], s[1]-st[1], s[2]-st[2]
+	rangeM = math.Sqrt(dx*dx + dy*dy + dz*dz)
+
+	latRad := station.Latitude * math.Pi / 180
+	lonRad := station.Longitude * math.Pi / 180
+	sinLat, cosLat := math.Sin(latRad), math.Cos(latRad)
+	sinLon, cosLon := math.Sin(lonRad), math.Cos(lonRad)
+
+	east := -sinLon*dx + cosLon*dy
+	north := -sinLat*cosLon*dx - sinLat*sinLon*dy + cosLat*dz
+	up := cosLat*cosLon*dx + cosLat*sinLon*dy + sinLat*dz
+
+	el = math.Asin(up/rangeM) * 180 / math.Pi
+	az = math.Mod(math.Atan2(east, north)*180/math.Pi+360, 360)
+	return el, az, rangeM
+}
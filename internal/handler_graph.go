@@ -0,0 +1,87 @@
+package demokubenet
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrHandlerCycle 处理器之间的依赖形成了环
+var ErrHandlerCycle = errors.New("handler dependency cycle")
+
+// HandlerOptions 描述处理器在一次事件中的执行顺序。
+// Phase 小的处理器全部完成后才开始执行 Phase 大的，同一阶段内并行；
+// After 列出必须先完成的同一事件的处理器名。前置处理器失败时本处理器被跳过
+type HandlerOptions struct {
+	Name  string
+	Phase int
+	After []string
+}
+
+// buildHandlerGraph 返回每个处理器需要等待的处理器下标
+func buildHandlerGraph(subs []subscription) ([][]int, error) {
+	byName := make(map[string]int, len(subs))
+	for i, sub := range subs {
+		if sub.opts.Name == "" {
+			continue
+		}
+		if _, ok := byName[sub.opts.Name]; ok {
+			return nil, fmt.Errorf("duplicate handler name %q", sub.opts.Name)
+		}
+		byName[sub.opts.Name] = i
+	}
+
+	deps := make([][]int, len(subs))
+	for i, sub := range subs {
+		for j, other := range subs {
+			if other.opts.Phase < sub.opts.Phase {
+				deps[i] = append(deps[i], j)
+			}
+		}
+		for _, name := range sub.opts.After {
+			j, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("handler %q depends on unknown handler %q", sub.opts.Name, name)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	// Kahn 拓扑排序检查是否有环
+	indegree := make([]int, len(subs))
+	dependents := make([][]int, len(subs))
+	for i, ds := range deps {
+		indegree[i] = len(ds)
+		for _, j := range ds {
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+	var ready []int
+	for i, d := range indegree {
+		if d == 0 {
+			ready = append(ready, i)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		i := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		visited++
+		for _, k := range dependents[i] {
+			indegree[k]--
+			if indegree[k] == 0 {
+				ready = append(ready, k)
+			}
+		}
+	}
+	if visited != len(subs) {
+		return nil, ErrHandlerCycle
+	}
+	return deps, nil
+}
+
+func handlerName(sub subscription) string {
+	if sub.opts.Name != "" {
+		return sub.opts.Name
+	}
+	return fmt.Sprintf("#%d", sub.id)
+}
@@ -0,0 +1,204 @@
+package demokubenet
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stepLog 记录处理器开始和结束的顺序
+type stepLog struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (l *stepLog) add(step string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.steps = append(l.steps, step)
+}
+
+func (l *stepLog) index(step string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, s := range l.steps {
+		if s == step {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *stepLog) handler(name string, err error) EventHandler {
+	return func(*EventBus, Event) error {
+		l.add(name + " start")
+		defer l.add(name + " end")
+		return err
+	}
+}
+
+func TestHandlerPhasesAndDependencies(t *testing.T) {
+	eb := NewEventBus(10)
+	var trace stepLog
+	// 同一阶段的 a 和 b 必须并行：各自等对方开始
+	aStarted, bStarted := make(chan struct{}), make(chan struct{})
+	rendezvous := func(name string, mine, other chan struct{}) EventHandler {
+		return func(*EventBus, Event) error {
+			trace.add(name + " start")
+			defer trace.add(name + " end")
+			close(mine)
+			select {
+			case <-other:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New(name + ": same-phase handler did not run in parallel")
+			}
+		}
+	}
+	// 按注册顺序反着声明依赖，确认顺序来自依赖而不是注册顺序
+	eb.SubscribeWithOptions("round", trace.handler("links", nil), HandlerOptions{Name: "links", Phase: 1, After: []string{"weather"}})
+	eb.SubscribeWithOptions("round", trace.handler("weather", nil), HandlerOptions{Name: "weather", Phase: 1})
+	eb.SubscribeWithOptions("round", rendezvous("a", aStarted, bStarted), HandlerOptions{Name: "a"})
+	eb.SubscribeWithOptions("round", rendezvous("b", bStarted, aStarted), HandlerOptions{Name: "b"})
+	startBus(t, eb)
+
+	if err := waitFuture(t, eb.Publish(Event{Type: "round"})); err != nil {
+		t.Fatal(err)
+	}
+	before := func(x, y string) {
+		t.Helper()
+		if i, j := trace.index(x), trace.index(y); i < 0 || j < 0 || i > j {
+			t.Errorf("%q (%d) not before %q (%d): %v", x, i, y, j, trace.steps)
+		}
+	}
+	before("a end", "weather start")
+	before("b end", "weather start")
+	before("weather end", "links start")
+}
+
+func TestHandlerGraphErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		opts    []HandlerOptions
+		wantIs  error
+		wantMsg string
+	}{
+		{
+			name:   "cycle",
+			opts:   []HandlerOptions{{Name: "x", After: []string{"y"}}, {Name: "y", After: []string{"z"}}, {Name: "z", After: []string{"x"}}},
+			wantIs: ErrHandlerCycle,
+		},
+		{
+			// 阶段顺序与依赖矛盾同样是环
+			name:   "phase cycle",
+			opts:   []HandlerOptions{{Name: "early", After: []string{"late"}}, {Name: "late", Phase: 1}},
+			wantIs: ErrHandlerCycle,
+		},
+		{
+			name:    "unknown",
+			opts:    []HandlerOptions{{Name: "x", After: []string{"missing"}}},
+			wantMsg: `depends on unknown handler "missing"`,
+		},
+		{
+			name:    "duplicate",
+			opts:    []HandlerOptions{{Name: "x"}, {Name: "x"}},
+			wantMsg: `duplicate handler name "x"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			eb := NewEventBus(10)
+			var trace stepLog
+			for _, opts := range c.opts {
+				eb.SubscribeWithOptions("round", trace.handler(opts.Name, nil), opts)
+			}
+			startBus(t, eb)
+
+			err := waitFuture(t, eb.Publish(Event{Type: "round"}))
+			if err == nil {
+				t.Fatal("want error")
+			}
+			if c.wantIs != nil && !errors.Is(err, c.wantIs) {
+				t.Errorf("error %v, want %v", err, c.wantIs)
+			}
+			if c.wantMsg != "" && !strings.Contains(err.Error(), c.wantMsg) {
+				t.Errorf("error %v, want %q", err, c.wantMsg)
+			}
+			// 图不合法时不执行任何处理器
+			if len(trace.steps) != 0 {
+				t.Errorf("handlers ran: %v", trace.steps)
+			}
+		})
+	}
+}
+
+func TestHandlerSkippedAfterFailedDependency(t *testing.T) {
+	errPropagate := errors.New("propagate failed")
+	eb := NewEventBus(10)
+	var trace stepLog
+	eb.SubscribeWithOptions("round", trace.handler("propagate", errPropagate), HandlerOptions{Name: "propagate"})
+	eb.SubscribeWithOptions("round", trace.handler("weather", nil), HandlerOptions{Name: "weather"})
+	eb.SubscribeWithOptions("round", trace.handler("links", nil), HandlerOptions{Name: "links", After: []string{"propagate", "weather"}})
+	eb.SubscribeWithOptions("round", trace.handler("publish", nil), HandlerOptions{Name: "publish", Phase: 1})
+	startBus(t, eb)
+
+	err := waitFuture(t, eb.Publish(Event{Type: "round"}))
+	if !errors.Is(err, errPropagate) {
+		t.Errorf("error %v, want %v", err, errPropagate)
+	}
+	for _, want := range []string{"handler links skipped: propagate failed", "handler publish skipped"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v, want %q", err, want)
+		}
+	}
+	if trace.index("weather end") < 0 {
+		t.Error("independent handler did not run")
+	}
+	// 跳过是传递的：links 被跳过后，下一阶段的 publish 也不执行
+	for _, skipped := range []string{"links start", "publish start"} {
+		if trace.index(skipped) >= 0 {
+			t.Errorf("%s: %v", skipped, trace.steps)
+		}
+	}
+}
+
+func TestSetSequential(t *testing.T) {
+	eb := NewEventBus(10)
+	var trace stepLog
+	var mu sync.Mutex
+	running, overlap := 0, false
+	eb.Subscribe("round", func(_ *EventBus, evt Event) error {
+		mu.Lock()
+		running++
+		overlap = overlap || running > 1
+		mu.Unlock()
+		// 先发布的事件耗时更长，并发时会后完成
+		i := evt.Payload.(int)
+		time.Sleep(time.Duration(5-i) * 2 * time.Millisecond)
+		trace.add(string(rune('0' + i)))
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	eb.SetSequential("round", true)
+	startBus(t, eb)
+
+	var futures []*Future
+	for i := 0; i < 5; i++ {
+		futures = append(futures, eb.Publish(Event{Type: "round", Payload: i}))
+	}
+	for _, f := range futures {
+		if err := waitFuture(t, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if overlap {
+		t.Error("sequential events overlapped")
+	}
+	if got := strings.Join(trace.steps, ""); got != "01234" {
+		t.Errorf("completion order %q, want 01234", got)
+	}
+}
@@ -0,0 +1,385 @@
+package demokubenet
+
+import (
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/joshuaferrara/go-satellite"
+)
+
+// ISLMode 星间链路的拓扑生成方式
+type ISLMode int
+
+const (
+	// ISLNone 不生成星间链路
+	ISLNone ISLMode = iota
+	// ISLGrid +Grid：同一轨道面内前后相邻的卫星，以及相邻轨道面中相位最接近的卫星
+	ISLGrid
+	// ISLNearest 每颗卫星连接距离最近的 Neighbors 颗卫星
+	ISLNearest
+	// ISLRange 连接距离不超过 MaxRange 且互相可见的所有卫星对，对应激光终端的作用距离
+	ISLRange
+)
+
+// ISLConfig 星间链路的配置
+type ISLConfig struct {
+	Mode ISLMode
+	// ISLNearest 模式下每颗卫星的邻居数
+	Neighbors int
+	// 最大链路距离 (m)，为 0 时不限制，对所有模式生效
+	MaxRange float64
+	// 视线与地球表面的最小距离 (m)，低于该高度的视线被地球和大气遮挡
+	GrazingAltitude float64
+	// 划分轨道面时允许的升交点赤经和倾角差 (度)
+	PlaneTolerance float64
+	// 星间链路的容量 (bit/s)
+	Bandwidth float64
+}
+
+func DefaultISLConfig() ISLConfig {
+	return ISLConfig{
+		Mode:            ISLNone,
+		Neighbors:       4,
+		MaxRange:        5000e3,
+		GrazingAltitude: 80e3,
+		PlaneTolerance:  1,
+		Bandwidth:       10e9,
+	}
+}
+
+// orbitState 由当前惯性系状态得到的轨道面参数 (度)，用于 +Grid 划分轨道面和排序
+type orbitState struct {
+	raan   float64 // 升交点赤经
+	incl   float64 // 倾角
+	argLat float64 // 纬度幅角
+}
+
+func newOrbitState(r, v satellite.Vector3) orbitState {
+	hx, hy, hz := r.Y*v.Z-r.Z*v.Y, r.Z*v.X-r.X*v.Z, r.X*v.Y-r.Y*v.X
+	h := math.Sqrt(hx*hx + hy*hy + hz*hz)
+	rn := math.Sqrt(r.X*r.X + r.Y*r.Y + r.Z*r.Z)
+	if h == 0 || rn == 0 {
+		return orbitState{}
+	}
+
+	// 升交点方向 n = z × h
+	nx, ny := -hy, hx
+	nn := math.Hypot(nx, ny)
+	if nn == 0 {
+		// 赤道轨道没有升交点，从 x 轴起算
+		nx, ny, nn = 1, 0, 1
+	}
+	nx, ny = nx/nn, ny/nn
+	// 轨道面内与 n 垂直的方向 m = ĥ × n
+	mx, my, mz := -hz/h*ny, hz/h*nx, (hx*ny-hy*nx)/h
+
+	var o orbitState
+	o.incl = math.Acos(hz/h) * 180 / math.Pi
+	o.raan = math.Mod(math.Atan2(ny, nx)*180/math.Pi+360, 360)
+	o.argLat = math.Mod(math.Atan2(r.X*mx+r.Y*my+r.Z*mz, r.X*nx+r.Y*ny)*180/math.Pi+360, 360)
+	return o
+}
+
+// islKey 星间链路表中的键，两颗卫星不分先后
+type islKey struct {
+	a, b *Satellite
+}
+
+func islKeyOf(link *LinkCache) islKey {
+	a, _ := link.SrcNode.(*Satellite)
+	b, _ := link.DstNode.(*Satellite)
+	return islKey{a: a, b: b}
+}
+
+func (e *EmulationInstance) findISL(a, b *Satellite) (int, bool) {
+	if i, ok := e.islIndex[islKey{a: a, b: b}]; ok {
+		return i, true
+	}
+	i, ok := e.islIndex[islKey{a: b, b: a}]
+	return i, ok
+}
+
+// lineOfSight ECEF 中两点之间的线段不与半径为 radius 的球相交时返回 true
+func lineOfSight(a, b [3]float64, radius float64) bool {
+	d := [3]float64{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+	dd := dot(d, d)
+	t := 0.0
+	if dd > 0 {
+		t = math.Max(0, math.Min(1, -dot(a, d)/dd))
+	}
+	p := [3]float64{a[0] + t*d[0], a[1] + t*d[1], a[2] + t*d[2]}
+	return dot(p, p) > radius*radius
+}
+
+func distance(a, b [3]float64) float64 {
+	d := [3]float64{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+	return math.Sqrt(dot(d, d))
+}
+
+// islPairs 按配置生成本轮的候选卫星对
+func islPairs(sats []*Satellite, cfg ISLConfig) []islKey {
+	switch cfg.Mode {
+	case ISLGrid:
+		return gridPairs(sats, cfg.PlaneTolerance)
+	case ISLNearest:
+		return nearestPairs(sats, cfg)
+	case ISLRange:
+		return rangePairs(sats, cfg)
+	}
+	return nil
+}
+
+// visible 两颗卫星之间的距离不超过 MaxRange 且视线不被地球遮挡
+func (cfg ISLConfig) visible(a, b *Satellite) bool {
+	if cfg.MaxRange > 0 && distance(a.state.R, b.state.R) > cfg.MaxRange {
+		return false
+	}
+	return lineOfSight(a.state.R, b.state.R, A+cfg.GrazingAltitude)
+}
+
+// islPlane 一个轨道面，raan 为面内第一颗卫星的升交点赤经
+type islPlane struct {
+	raan float64
+	sats []*Satellite
+}
+
+// orbitPlanes 按倾角把卫星分为壳层，壳层内按升交点赤经划分轨道面，返回的轨道面按升交点赤经递增
+func orbitPlanes(sats []*Satellite, tol float64) [][]islPlane {
+	sorted := make([]*Satellite, len(sats))
+	copy(sorted, sats)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].orbit.incl < sorted[j].orbit.incl })
+
+	var shells [][]islPlane
+	for i := 0; i < len(sorted); {
+		j := i + 1
+		for j < len(sorted) && sorted[j].orbit.incl-sorted[j-1].orbit.incl <= tol {
+			j++
+		}
+		shell := sorted[i:j]
+		sort.Slice(shell, func(a, b int) bool { return shell[a].orbit.raan < shell[b].orbit.raan })
+
+		var planes []islPlane
+		for k, sat := range shell {
+			if k == 0 || sat.orbit.raan-shell[k-1].orbit.raan > tol {
+				planes = append(planes, islPlane{raan: sat.orbit.raan})
+			}
+			planes[len(planes)-1].sats = append(planes[len(planes)-1].sats, sat)
+		}
+		// 升交点赤经跨过 0 度的轨道面被拆成了首尾两段，合并到第一个轨道面
+		if n := len(planes); n > 1 {
+			last := planes[n-1].sats
+			if planes[0].sats[0].orbit.raan+360-last[len(last)-1].orbit.raan <= tol {
+				planes[0] = islPlane{raan: planes[n-1].raan - 360, sats: append(last, planes[0].sats...)}
+				planes = planes[:n-1]
+			}
+		}
+		shells = append(shells, planes)
+		i = j
+	}
+	return shells
+}
+
+// gridPairs 生成 +Grid 拓扑：同一轨道面按纬度幅角排成环，相邻轨道面之间连接相位最接近的卫星。
+// 最后一个轨道面与第一个之间的间隔不超过平均间隔的两倍时（轨道面布满 360 度）也相互连接，
+// 否则视为 Walker star 星座的接缝，不跨接缝建链
+func gridPairs(sats []*Satellite, tol float64) []islKey {
+	var pairs []islKey
+	for _, planes := range orbitPlanes(sats, tol) {
+		for _, plane := range planes {
+			ring := plane.sats
+			sort.Slice(ring, func(i, j int) bool { return ring[i].orbit.argLat < ring[j].orbit.argLat })
+			for i := range ring {
+				next := i + 1
+				if next == len(ring) {
+					if len(ring) < 3 {
+						break
+					}
+					next = 0
+				}
+				pairs = append(pairs, islKey{a: ring[i], b: ring[next]})
+			}
+		}
+
+		n := len(planes)
+		if n < 2 {
+			continue
+		}
+		span := planes[n-1].raan - planes[0].raan
+		wrap := n >= 3 && 360-span <= 2*span/float64(n-1)
+		for p := 0; p < n; p++ {
+			q := p + 1
+			if q == n {
+				if !wrap {
+					break
+				}
+				q = 0
+			}
+			for _, sat := range planes[p].sats {
+				var best *Satellite
+				bestDiff := math.Inf(1)
+				for _, other := range planes[q].sats {
+					diff := math.Abs(math.Mod(sat.orbit.argLat-other.orbit.argLat+540, 360) - 180)
+					if diff < bestDiff {
+						best, bestDiff = other, diff
+					}
+				}
+				pairs = append(pairs, islKey{a: sat, b: best})
+			}
+		}
+	}
+	return pairs
+}
+
+// islSearch 按卫星当前位置建立的 k-d 树，以及每颗卫星可能可见的最大距离
+type islSearch struct {
+	tree *kdTree
+	rmax float64
+}
+
+func newISLSearch(sats []*Satellite) islSearch {
+	points := make([][3]float64, len(sats))
+	s := islSearch{}
+	for i, sat := range sats {
+		points[i] = sat.state.R
+		s.rmax = math.Max(s.rmax, math.Sqrt(dot(sat.state.R, sat.state.R)))
+	}
+	s.tree = newKDTree(points)
+	return s
+}
+
+// reach a 与其他卫星的视线不被地球遮挡时的最大距离，不超过 MaxRange
+func (s islSearch) reach(a *Satellite, cfg ISLConfig) float64 {
+	r := horizonRange(math.Sqrt(dot(a.state.R, a.state.R)), s.rmax, A+cfg.GrazingAltitude)
+	if cfg.MaxRange > 0 {
+		r = math.Min(r, cfg.MaxRange)
+	}
+	return r
+}
+
+// nearestPairs 每颗卫星与最近的 Neighbors 颗可见卫星组成候选对，重复的卫星对只保留一个
+func nearestPairs(sats []*Satellite, cfg ISLConfig) []islKey {
+	search := newISLSearch(sats)
+	seen := make(map[islKey]bool)
+	var pairs []islKey
+	for i, a := range sats {
+		accept := func(j int) bool { return j != i && cfg.visible(a, sats[j]) }
+		for _, n := range search.tree.nearest(a.state.R, cfg.Neighbors, search.reach(a, cfg), accept) {
+			b := sats[n.i]
+			if seen[islKey{a: a, b: b}] || seen[islKey{a: b, b: a}] {
+				continue
+			}
+			seen[islKey{a: a, b: b}] = true
+			pairs = append(pairs, islKey{a: a, b: b})
+		}
+	}
+	return pairs
+}
+
+// rangePairs 所有可见的卫星对
+func rangePairs(sats []*Satellite, cfg ISLConfig) []islKey {
+	search := newISLSearch(sats)
+	var pairs []islKey
+	var hits []int
+	for i, a := range sats {
+		hits = hits[:0]
+		search.tree.within(a.state.R, search.reach(a, cfg), func(j int) {
+			if j > i && cfg.visible(a, sats[j]) {
+				hits = append(hits, j)
+			}
+		})
+		sort.Ints(hits)
+		for _, j := range hits {
+			pairs = append(pairs, islKey{a: a, b: sats[j]})
+		}
+	}
+	return pairs
+}
+
+// updateISLs 按 ISLConfig 生成本轮的星间链路并更新距离和时延，范围外的链路保持上一轮的结果。
+// 不再是候选的链路置为不可用，在上报删除之后由 pruneISLs 移出链路表
+func (e *EmulationInstance) updateISLs(scope linkScope) {
+	cfg := e.ISLConfig
+	if cfg.Mode == ISLNone && len(e.ISLs) == 0 {
+		return
+	}
+	log.Println("updateISLs...")
+	startTime := time.Now()
+	selected := make([]bool, len(e.ISLs))
+	for _, pair := range islPairs(e.Satellites, cfg) {
+		i, ok := e.findISL(pair.a, pair.b)
+		if !ok {
+			e.ISLs = append(e.ISLs, LinkCache{SrcNode: pair.a, DstNode: pair.b})
+			i = len(e.ISLs) - 1
+			e.islIndex[pair] = i
+			selected = append(selected, false)
+		}
+		selected[i] = true
+	}
+
+	count := 0
+	for i := range e.ISLs {
+		link := &e.ISLs[i]
+		if !scope.hasLink(link) {
+			continue
+		}
+		a, b := link.SrcNode.(*Satellite), link.DstNode.(*Satellite)
+		link.Range = distance(a.state.R, b.state.R)
+		link.Active = selected[i] && cfg.visible(a, b)
+		if !link.Active {
+			link.Delay, link.Bandwidth, link.Jitter, link.RangeRate = 0, 0, 0, 0
+			continue
+		}
+		count++
+		link.Delay = time.Duration(link.Range / SpeedOfLight * float64(time.Second))
+		link.Bandwidth = cfg.Bandwidth
+		link.Jitter = DefaultBaseJitter
+		link.RangeRate, _ = rangeRate(a.state, b.state)
+	}
+	log.Printf("updateISLs active count: %d", count)
+	log.Printf("updateISLs took %v", time.Since(startTime))
+}
+
+// removeISLsWhere 删除满足条件的星间链路，已上报过的记入 pendingRemoved
+func (e *EmulationInstance) removeISLsWhere(match func(*LinkCache) bool) {
+	for i := 0; i < len(e.ISLs); {
+		if !match(&e.ISLs[i]) {
+			i++
+			continue
+		}
+		delete(e.islIndex, islKeyOf(&e.ISLs[i]))
+		if e.ISLs[i].reported {
+			removedLink := e.ISLs[i]
+			removedLink.Active = false
+			e.pendingRemoved = append(e.pendingRemoved, LinkChange{Kind: LinkRemoved, Link: removedLink, PrevAr: removedLink.reportedAr})
+		}
+		last := len(e.ISLs) - 1
+		if i != last {
+			e.ISLs[i] = e.ISLs[last]
+			e.islIndex[islKeyOf(&e.ISLs[i])] = i
+		}
+		e.ISLs[last] = LinkCache{}
+		e.ISLs = e.ISLs[:last]
+	}
+}
+
+// pruneISLs 移出已经上报删除（或从未上报）的不可用星间链路
+func (e *EmulationInstance) pruneISLs() {
+	e.removeISLsWhere(func(link *LinkCache) bool { return !link.Active && !link.reported })
+}
+
+// ActiveISLs 返回当前可用的星间链路副本
+func (e *EmulationInstance) ActiveISLs() []LinkCache {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	e.linkMu.Lock()
+	defer e.linkMu.Unlock()
+	var active []LinkCache
+	for i := range e.ISLs {
+		if e.ISLs[i].Active {
+			active = append(active, e.ISLs[i])
+		}
+	}
+	return active
+}
@@ -0,0 +1,110 @@
+package demokubenet
+
+import (
+	"math"
+	"testing"
+)
+
+// walkerShell 生成 planes 个轨道面、每面 perPlane 颗卫星的 Walker 星座，
+// 轨道面升交点赤经从 raan0 起每面增加 dRAAN，相邻轨道面相位偏移 phase 度
+func walkerShell(planes, perPlane int, incl, raan0, dRAAN, phase float64) [][]*Satellite {
+	shell := make([][]*Satellite, planes)
+	for p := range shell {
+		raan := math.Mod(raan0+float64(p)*dRAAN+360, 360)
+		for s := 0; s < perPlane; s++ {
+			argLat := math.Mod(float64(s)*360/float64(perPlane)+float64(p)*phase, 360)
+			shell[p] = append(shell[p], &Satellite{orbit: orbitState{raan: raan, incl: incl, argLat: argLat}})
+		}
+	}
+	return shell
+}
+
+// islNeighbors 统计每颗卫星的面内与跨面邻居数，同时检查卫星对不重复
+func islNeighbors(t *testing.T, pairs []islKey, plane map[*Satellite]int) (intra, cross map[*Satellite]int) {
+	t.Helper()
+	intra, cross = map[*Satellite]int{}, map[*Satellite]int{}
+	seen := map[islKey]bool{}
+	for _, k := range pairs {
+		if k.a == k.b {
+			t.Fatalf("self link in plane %d", plane[k.a])
+		}
+		if seen[k] || seen[islKey{a: k.b, b: k.a}] {
+			t.Fatalf("duplicate pair between planes %d and %d", plane[k.a], plane[k.b])
+		}
+		seen[k] = true
+		count := cross
+		if plane[k.a] == plane[k.b] {
+			count = intra
+		}
+		count[k.a]++
+		count[k.b]++
+	}
+	return intra, cross
+}
+
+func TestGridPairsWalker(t *testing.T) {
+	// Walker delta 53°:6/48/1，RAAN 为 0 的轨道面一半卫星在 359.8°，一半在 0.2°，
+	// 应合并为一个轨道面，且最后一个轨道面与第一个相连
+	delta := walkerShell(6, 8, 53, 0, 60, 7.5)
+	for i, sat := range delta[0] {
+		sat.orbit.raan = 0.2
+		if i%2 == 1 {
+			sat.orbit.raan = 359.8
+		}
+	}
+	// Walker star 87°：6 个轨道面只覆盖 0~150°，150° 与 0° 之间是接缝
+	star := walkerShell(6, 10, 87, 0, 30, 9)
+
+	plane := map[*Satellite]int{}
+	var sats []*Satellite
+	for p, ring := range append(delta, star...) {
+		for _, sat := range ring {
+			plane[sat] = p
+			sats = append(sats, sat)
+		}
+	}
+
+	shells := orbitPlanes(sats, 1)
+	if len(shells) != 2 {
+		t.Fatalf("orbitPlanes: %d shells, want 2", len(shells))
+	}
+	for s, want := range [][][]*Satellite{delta, star} {
+		planes := shells[s]
+		if len(planes) != len(want) {
+			t.Fatalf("shell %d: %d planes, want %d", s, len(planes), len(want))
+		}
+		for i, pl := range planes {
+			if len(pl.sats) != len(want[i]) {
+				t.Fatalf("shell %d plane %d: %d satellites, want %d", s, i, len(pl.sats), len(want[i]))
+			}
+			for _, sat := range pl.sats {
+				if plane[sat] != plane[want[i][0]] {
+					t.Fatalf("shell %d plane %d: satellite from plane %d", s, i, plane[sat])
+				}
+			}
+		}
+	}
+
+	intra, cross := islNeighbors(t, gridPairs(sats, 1), plane)
+	for p, ring := range delta {
+		for _, sat := range ring {
+			if intra[sat] != 2 || cross[sat] != 2 {
+				t.Errorf("delta plane %d argLat %g: %d intra-plane, %d cross-plane neighbours, want 2 and 2",
+					p, sat.orbit.argLat, intra[sat], cross[sat])
+			}
+		}
+	}
+	for p, ring := range star {
+		// 接缝两侧的轨道面只有一个相邻轨道面
+		wantCross := 2
+		if p == 0 || p == len(star)-1 {
+			wantCross = 1
+		}
+		for _, sat := range ring {
+			if intra[sat] != 2 || cross[sat] != wantCross {
+				t.Errorf("star plane %d argLat %g: %d intra-plane, %d cross-plane neighbours, want 2 and %d",
+					p, sat.orbit.argLat, intra[sat], cross[sat], wantCross)
+			}
+		}
+	}
+}
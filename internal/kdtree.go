@@ -0,0 +1,142 @@
+package demokubenet
+
+import (
+	"math"
+	"sort"
+)
+
+// kdTree ECEF 坐标上的静态 k-d 树，每轮按卫星的当前位置重新构建。
+// 树隐式存放在 idx 中：区间 [lo, hi) 的中点为该子树的根，按深度依次以 x、y、z 划分
+type kdTree struct {
+	points [][3]float64
+	idx    []int
+}
+
+func newKDTree(points [][3]float64) *kdTree {
+	t := &kdTree{points: points, idx: make([]int, len(points))}
+	for i := range t.idx {
+		t.idx[i] = i
+	}
+	t.build(0, len(t.idx), 0)
+	return t
+}
+
+func (t *kdTree) build(lo, hi, depth int) {
+	if hi-lo <= 1 {
+		return
+	}
+	axis := depth % 3
+	sub := t.idx[lo:hi]
+	sort.Slice(sub, func(i, j int) bool { return t.points[sub[i]][axis] < t.points[sub[j]][axis] })
+	mid := (lo + hi) / 2
+	t.build(lo, mid, depth+1)
+	t.build(mid+1, hi, depth+1)
+}
+
+func squaredDistance(a, b [3]float64) float64 {
+	d := [3]float64{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+	return dot(d, d)
+}
+
+// within 对与 q 的距离不超过 r 的每个点调用 f
+func (t *kdTree) within(q [3]float64, r float64, f func(i int)) {
+	t.searchWithin(0, len(t.idx), 0, q, r*r, f)
+}
+
+func (t *kdTree) searchWithin(lo, hi, depth int, q [3]float64, r2 float64, f func(i int)) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	i := t.idx[mid]
+	p := t.points[i]
+	if squaredDistance(q, p) <= r2 {
+		f(i)
+	}
+	diff := q[depth%3] - p[depth%3]
+	if diff <= 0 || diff*diff <= r2 {
+		t.searchWithin(lo, mid, depth+1, q, r2, f)
+	}
+	if diff >= 0 || diff*diff <= r2 {
+		t.searchWithin(mid+1, hi, depth+1, q, r2, f)
+	}
+}
+
+// kdNeighbor nearest 的结果，d2 为距离的平方
+type kdNeighbor struct {
+	i  int
+	d2 float64
+}
+
+// nearest 返回与 q 的距离不超过 r 且 accept 为 true 的最近 k 个点，按距离递增
+func (t *kdTree) nearest(q [3]float64, k int, r float64, accept func(i int) bool) []kdNeighbor {
+	if k <= 0 {
+		return nil
+	}
+	s := kdSearch{tree: t, q: q, k: k, r2: r * r, accept: accept}
+	s.search(0, len(t.idx), 0)
+	return s.best
+}
+
+type kdSearch struct {
+	tree   *kdTree
+	q      [3]float64
+	k      int
+	r2     float64
+	accept func(i int) bool
+	best   []kdNeighbor
+}
+
+// bound 当前的搜索半径的平方：找满 k 个之后为第 k 近的距离
+func (s *kdSearch) bound() float64 {
+	if len(s.best) == s.k {
+		return s.best[len(s.best)-1].d2
+	}
+	return s.r2
+}
+
+func (s *kdSearch) search(lo, hi, depth int) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	i := s.tree.idx[mid]
+	p := s.tree.points[i]
+	if d2 := squaredDistance(s.q, p); d2 <= s.bound() && s.accept(i) {
+		s.insert(kdNeighbor{i: i, d2: d2})
+	}
+	diff := s.q[depth%3] - p[depth%3]
+	near, far := [2]int{lo, mid}, [2]int{mid + 1, hi}
+	if diff > 0 {
+		near, far = far, near
+	}
+	s.search(near[0], near[1], depth+1)
+	if diff*diff <= s.bound() {
+		s.search(far[0], far[1], depth+1)
+	}
+}
+
+// insert 按距离插入，距离相同时下标小的在前，结果与树的形状无关
+func (s *kdSearch) insert(n kdNeighbor) {
+	j := sort.Search(len(s.best), func(j int) bool {
+		b := s.best[j]
+		return b.d2 > n.d2 || b.d2 == n.d2 && b.i > n.i
+	})
+	if j == s.k {
+		return
+	}
+	if len(s.best) < s.k {
+		s.best = append(s.best, kdNeighbor{})
+	}
+	copy(s.best[j+1:], s.best[j:])
+	s.best[j] = n
+}
+
+// horizonRange 半径为 ra 和 rb 的两点之间视线不低于半径 radius 的最大距离，
+// 任一点在半径 radius 以内时为 0
+func horizonRange(ra, rb, radius float64) float64 {
+	if ra <= radius || rb <= radius {
+		return 0
+	}
+	return math.Sqrt(ra*ra-radius*radius) + math.Sqrt(rb*rb-radius*radius)
+}
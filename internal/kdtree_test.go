@@ -0,0 +1,88 @@
+package demokubenet
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// randomShell 在半径 r0 到 r1 (m) 的球壳内随机生成 n 颗卫星
+func randomShell(rng *rand.Rand, n int, r0, r1 float64) []*Satellite {
+	sats := make([]*Satellite, n)
+	for i := range sats {
+		z := 2*rng.Float64() - 1
+		phi := 2 * math.Pi * rng.Float64()
+		r := r0 + (r1-r0)*rng.Float64()
+		rho := math.Sqrt(1 - z*z)
+		sats[i] = &Satellite{state: ecefState{R: [3]float64{r * rho * math.Cos(phi), r * rho * math.Sin(phi), r * z}}}
+	}
+	return sats
+}
+
+// bruteRangePairs 与 rangePairs 相同顺序的逐对检查结果
+func bruteRangePairs(sats []*Satellite, cfg ISLConfig) []islKey {
+	var pairs []islKey
+	for i, a := range sats {
+		for _, b := range sats[i+1:] {
+			if cfg.visible(a, b) {
+				pairs = append(pairs, islKey{a: a, b: b})
+			}
+		}
+	}
+	return pairs
+}
+
+// bruteNearest a 的最近 k 颗可见卫星的距离
+func bruteNearest(sats []*Satellite, a *Satellite, k int, cfg ISLConfig) []float64 {
+	var dists []float64
+	for _, b := range sats {
+		if b != a && cfg.visible(a, b) {
+			dists = append(dists, distance(a.state.R, b.state.R))
+		}
+	}
+	sort.Float64s(dists)
+	if len(dists) > k {
+		dists = dists[:k]
+	}
+	return dists
+}
+
+func TestISLSpatialIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sats := randomShell(rng, 400, A+500e3, A+1200e3)
+	configs := []ISLConfig{
+		{Neighbors: 4},
+		{Neighbors: 4, MaxRange: 2000e3},
+		{Neighbors: 6, MaxRange: 5000e3, GrazingAltitude: 80e3},
+	}
+	for _, cfg := range configs {
+		t.Run(fmt.Sprintf("range %g grazing %g", cfg.MaxRange, cfg.GrazingAltitude), func(t *testing.T) {
+			got, want := rangePairs(sats, cfg), bruteRangePairs(sats, cfg)
+			if len(got) != len(want) {
+				t.Fatalf("rangePairs: %d pairs, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("rangePairs[%d] differs from brute force", i)
+				}
+			}
+
+			search := newISLSearch(sats)
+			for i, a := range sats {
+				accept := func(j int) bool { return j != i && cfg.visible(a, sats[j]) }
+				got := search.tree.nearest(a.state.R, cfg.Neighbors, search.reach(a, cfg), accept)
+				want := bruteNearest(sats, a, cfg.Neighbors, cfg)
+				if len(got) != len(want) {
+					t.Fatalf("satellite %d: %d neighbors, want %d", i, len(got), len(want))
+				}
+				for k := range got {
+					if d := math.Sqrt(got[k].d2); math.Abs(d-want[k]) > 1e-6 {
+						t.Fatalf("satellite %d neighbor %d: distance %g, want %g", i, k, d, want[k])
+					}
+				}
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package demokubenet
 import (
 	"bufio"
 	"demokubenet/itur"
-	"demokubenet/utils"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,8 +12,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/joshuaferrara/go-satellite"
 )
 
 type HourlyData struct {
@@ -30,11 +27,11 @@ type StationWeather struct {
 }
 
 type Link struct {
-	Uid int64 `protobuf:"varint,1,opt,name=uid,proto3" json:"uid,omitempty"`
-	Src int32 `protobuf:"varint,2,opt,name=src,proto3" json:"src,omitempty"`
-	Dst int32 `protobuf:"varint,3,opt,name=dst,proto3" json:"dst,omitempty"`
-	// Properties     *LinkProperties `protobuf:"bytes,4,opt,name=properties,proto3" json:"properties,omitempty"`
-	UniDirectional bool `protobuf:"varint,5,opt,name=uni_directional,json=uniDirectional,proto3" json:"uni_directional,omitempty"` // Not support update this field! Please delete and re-add
+	Uid            int64           `protobuf:"varint,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Src            int32           `protobuf:"varint,2,opt,name=src,proto3" json:"src,omitempty"`
+	Dst            int32           `protobuf:"varint,3,opt,name=dst,proto3" json:"dst,omitempty"`
+	Properties     *LinkProperties `protobuf:"bytes,4,opt,name=properties,proto3" json:"properties,omitempty"`
+	UniDirectional bool            `protobuf:"varint,5,opt,name=uni_directional,json=uniDirectional,proto3" json:"uni_directional,omitempty"` // Not support update this field! Please delete and re-add
 	// newly added
 	SrcNs string `protobuf:"bytes,6,opt,name=src_ns,json=srcNs,proto3" json:"src_ns,omitempty"`
 	DstNs string `protobuf:"bytes,7,opt,name=dst_ns,json=dstNs,proto3" json:"dst_ns,omitempty"`
@@ -42,6 +39,22 @@ type Link struct {
 	// Status    *LinkStatus `protobuf:"bytes,9,opt,name=status,proto3" json:"status,omitempty"`
 }
 
+type LinkProperties struct {
+	Latency       string `protobuf:"bytes,1,opt,name=latency,proto3" json:"latency,omitempty"`
+	LatencyCorr   string `protobuf:"bytes,2,opt,name=latency_corr,json=latencyCorr,proto3" json:"latency_corr,omitempty"`
+	Jitter        string `protobuf:"bytes,3,opt,name=jitter,proto3" json:"jitter,omitempty"`
+	Loss          string `protobuf:"bytes,4,opt,name=loss,proto3" json:"loss,omitempty"`
+	LossCorr      string `protobuf:"bytes,5,opt,name=loss_corr,json=lossCorr,proto3" json:"loss_corr,omitempty"`
+	Rate          string `protobuf:"bytes,6,opt,name=rate,proto3" json:"rate,omitempty"`
+	Gap           uint32 `protobuf:"varint,7,opt,name=gap,proto3" json:"gap,omitempty"`
+	Duplicate     string `protobuf:"bytes,8,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	DuplicateCorr string `protobuf:"bytes,9,opt,name=duplicate_corr,json=duplicateCorr,proto3" json:"duplicate_corr,omitempty"`
+	ReorderProb   string `protobuf:"bytes,10,opt,name=reorder_prob,json=reorderProb,proto3" json:"reorder_prob,omitempty"`
+	ReorderCorr   string `protobuf:"bytes,11,opt,name=reorder_corr,json=reorderCorr,proto3" json:"reorder_corr,omitempty"`
+	CorruptProb   string `protobuf:"bytes,12,opt,name=corrupt_prob,json=corruptProb,proto3" json:"corrupt_prob,omitempty"`
+	CorruptCorr   string `protobuf:"bytes,13,opt,name=corrupt_corr,json=corruptCorr,proto3" json:"corrupt_corr,omitempty"`
+}
+
 type LinkCache struct {
 	// Raw *Link
 
@@ -50,13 +63,44 @@ type LinkCache struct {
 
 	EnvIndex EnvironmentIndex
 
+	// 地面站看卫星的仰角、方位角 (度) 和斜距 (m)
+	Elevation float64
+	Azimuth   float64
+	Range     float64
+	// 仰角不低于地面站的仰角掩模时为 true，只有 Active 的链路会计算衰减
+	Active bool
+
+	// 链路单独的无线参数，nil 时使用地面站的配置
+	Radio *RadioConfig
+
+	// 大气总衰减 (dB)
 	Ar float64
+
+	// 斜距变化率 (m/s)、载波上的多普勒频移 (Hz) 及其变化率 (Hz/s)
+	RangeRate   float64
+	Doppler     float64
+	DopplerRate float64
+
+	// 链路预算，FSPL 使用实际斜距，大气损耗取 Ar
+	Budget LinkBudget
+
+	// 网络属性：传播时延、容量 (bit/s)、丢包率 (%) 和抖动，由斜距和链路预算得到
+	Delay     time.Duration
+	Bandwidth float64
+	Loss      float64
+	Jitter    time.Duration
+
+	// 上次通过 LinksChangedEvent 上报的状态
+	reported       bool
+	reportedAr     float64
+	reportedQuanta linkQuanta
 }
 
 type EnvironmentIndex struct {
-	Temperature2m float64
-	Precipitation float64
-	Pressure      float64 // hPa
+	Temperature2m    float64
+	Precipitation    float64
+	Pressure         float64 // hPa
+	CloudLiquidWater float64 // 云中液态水柱含量 (kg/m2)，为 0 时使用 P.840 在站点处的统计值
 }
 
 func readStationsCount(filename string, count int) ([]*Station, error) {
@@ -80,8 +124,9 @@ func readStationsCount(filename string, count int) ([]*Station, error) {
 		if len(fields) < 2 {
 			continue // 忽略格式不对的行
 		}
-		long, err1 := strconv.ParseFloat(fields[0], 64)
-		lat, err2 := strconv.ParseFloat(fields[1], 64)
+		// 站点文件每行为 纬度 经度 [海拔 [仰角掩模]]，与 weather/fetch_weather.go 一致
+		lat, err1 := strconv.ParseFloat(fields[0], 64)
+		long, err2 := strconv.ParseFloat(fields[1], 64)
 		if err1 != nil || err2 != nil {
 			continue // 忽略无法解析的行
 		}
@@ -89,13 +134,18 @@ func readStationsCount(filename string, count int) ([]*Station, error) {
 		if len(fields) >= 3 {
 			alt, _ = strconv.ParseFloat(fields[2], 64)
 		}
-		stations = append(stations, &Station{
-			position: Position{
-				Latitude:  lat,
-				Longitude: long,
-				Altitude:  alt,
-			},
+		station := NewStation(Position{
+			Latitude:  lat,
+			Longitude: long,
+			Altitude:  alt,
 		})
+		// 第四列为可选的仰角掩模
+		if len(fields) >= 4 {
+			if mask, err := strconv.ParseFloat(fields[3], 64); err == nil {
+				station.MinElevation = mask
+			}
+		}
+		stations = append(stations, station)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -123,10 +173,11 @@ func readStations(filename string) ([]*Station, error) {
 		}
 		fields := strings.Fields(line)
 		if len(fields) < 2 {
-			return nil, fmt.Errorf("station_data.txt 每行至少要包含经度和纬度: %s", line)
+			return nil, fmt.Errorf("station_data.txt 每行至少要包含纬度和经度: %s", line)
 		}
-		long, err1 := strconv.ParseFloat(fields[0], 64)
-		lat, err2 := strconv.ParseFloat(fields[1], 64)
+		// 站点文件每行为 纬度 经度 [海拔 [仰角掩模]]，与 weather/fetch_weather.go 一致
+		lat, err1 := strconv.ParseFloat(fields[0], 64)
+		long, err2 := strconv.ParseFloat(fields[1], 64)
 		if err1 != nil || err2 != nil {
 			return nil, fmt.Errorf("station_data.txt 格式错误: %s", line)
 		}
@@ -134,13 +185,18 @@ func readStations(filename string) ([]*Station, error) {
 		if len(fields) >= 3 {
 			alt, _ = strconv.ParseFloat(fields[2], 64)
 		}
-		stations = append(stations, &Station{
-			position: Position{
-				Latitude:  lat,
-				Longitude: long,
-				Altitude:  alt,
-			},
+		station := NewStation(Position{
+			Latitude:  lat,
+			Longitude: long,
+			Altitude:  alt,
 		})
+		// 第四列为可选的仰角掩模
+		if len(fields) >= 4 {
+			if mask, err := strconv.ParseFloat(fields[3], 64); err == nil {
+				station.MinElevation = mask
+			}
+		}
+		stations = append(stations, station)
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
@@ -172,12 +228,7 @@ func readSatellitesCount(filename string, count int) ([]*Satellite, error) {
 		if !strings.HasPrefix(line2, "2 ") {
 			return nil, fmt.Errorf("TLE第二行格式错误: %s", line2)
 		}
-		sat := &Satellite{
-			TleLine1:      line1,
-			TleLine2:      line2,
-			SGP4Satellite: satellite.TLEToSat(line1, line2, satellite.GravityWGS72),
-		}
-		sats = append(sats, sat)
+		sats = append(sats, NewSatellite(line1, line2))
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -214,11 +265,7 @@ func readSatellites(filename string) ([]*Satellite, error) {
 		if !strings.HasPrefix(line2, "2 ") {
 			return nil, fmt.Errorf("TLE第二行格式错误: %s", line2)
 		}
-		sats = append(sats, &Satellite{
-			TleLine1:      line1,
-			TleLine2:      line2,
-			SGP4Satellite: satellite.TLEToSat(line1, line2, satellite.GravityWGS72),
-		})
+		sats = append(sats, NewSatellite(line1, line2))
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
@@ -272,7 +319,7 @@ type WeatherIndex struct {
 	hr            float64
 }
 
-func getWeather(stationPos Position) WeatherIndex {
+func getWeather(stationPos Position, timestamp time.Time) WeatherIndex {
 	//temperature_2m: C
 	//precipitation: mm/h
 	//surface_pressure: hPa
@@ -301,8 +348,7 @@ func getWeather(stationPos Position) WeatherIndex {
 	if err != nil {
 		log.Fatalf("Error unmarshalling JSON: %v", err)
 	}
-	currentTime := time.Now()
-	hours, _, _ := currentTime.Clock()
+	hours, _, _ := timestamp.UTC().Clock()
 	// log.Printf("hours: %d", hours)
 
 	weatherIndex := &WeatherIndex{
@@ -327,12 +373,12 @@ func loadWeatherData(filePath string) ([]StationWeather, error) {
 	return data, nil
 }
 
-func getWeatherFromFile(stationPos Position) WeatherIndex {
+func getWeatherFromFile(stationPos Position, timestamp time.Time) WeatherIndex {
 	weatherData, err := loadWeatherData("data/weather_data.json")
 	if err != nil {
 		log.Fatalf("Error loading weather data: %v", err)
 	}
-	hours := time.Now().Hour()
+	hours := timestamp.UTC().Hour()
 
 	for _, wd := range weatherData {
 		if wd.Lat == stationPos.Latitude && wd.Lon == stationPos.Longitude {
@@ -346,22 +392,74 @@ func getWeatherFromFile(stationPos Position) WeatherIndex {
 	return WeatherIndex{}
 }
 
-func CalculateSatelliteLink(link *LinkCache, satellitePos Position, stationPos Position, pre float64) float64 {
+// 默认载波频率 (GHz)
+const DefaultFrequency = 22.5
+
+// 默认的时间百分比 p (%)，链路衰减为年平均时间的 p% 被超过的值
+const DefaultTimePercentage = 0.1
+
+// 常用的极化倾角 (度)
+const (
+	PolarizationHorizontal = 0.0
+	PolarizationCircular   = 45.0
+	PolarizationVertical   = 90.0
+)
+
+// RadioConfig 链路的无线参数，同一仿真中不同类型的终端可以使用不同的配置
+type RadioConfig struct {
+	Frequency      float64 // 载波频率 (GHz)，为 0 时使用卫星发射机或 LinkUpdatePayload 中的频率
+	Polarization   float64 // 极化倾角 tau (度)
+	TimePercentage float64 // 时间百分比 p (%)，为 0 时使用 DefaultTimePercentage
+}
+
+func DefaultRadioConfig() RadioConfig {
+	return RadioConfig{
+		Polarization:   PolarizationCircular,
+		TimePercentage: DefaultTimePercentage,
+	}
+}
+
+// RainRateMode 计算雨衰使用的降雨率来源
+type RainRateMode int
+
+const (
+	// RainRateInstantaneous 使用天气数据中的当前降水量作为降雨率，得到当前的雨衰，
+	// 气体、云和闪烁仍按 RadioConfig 中的时间百分比计算
+	RainRateInstantaneous RainRateMode = iota
+	// RainRateStatistical 使用 P.837 在站点处的 R0.01，得到 p% 时间被超过的衰减。
+	// itur 不附带 P.837-7 数字地图，需要先用 itur.LoadMonthlyRainGrids 读入 12 个月的地图，
+	// 否则与 RainRateInstantaneous 相同
+	RainRateStatistical
+)
+
+// CalculateSatelliteLink 计算链路倾斜路径上的大气总衰减 (dB)，包括降雨、气体、云和闪烁，
+// R001 为 0.01% 时间被超过的降雨率 (mm/h)，频率、极化和时间百分比取自 radio。
+// mode 为 RainRateInstantaneous 时 R001 是当前降雨率，雨衰按 0.01% 计算即当前的衰减，
+// 气体、云和闪烁仍按 radio 中的时间百分比计算
+func CalculateSatelliteLink(link *LinkCache, stationPos Position, env EnvironmentIndex, R001 float64, radio RadioConfig, mode RainRateMode) float64 {
 	//TODO
 	//天气数据的获取
-	// weatherIndex := getWeather(stationPos)
-	// weatherIndex := getWeatherFromFile(stationPos)
+	// weatherIndex := getWeather(stationPos, timestamp)
+	// weatherIndex := getWeatherFromFile(stationPos, timestamp)
 	// pre := link.EnvIndex.Precipitation
 	latGS, lonGS := stationPos.Latitude, stationPos.Longitude
-	el := utils.Elevation_angle(satellitePos.Altitude, satellitePos.Latitude, satellitePos.Longitude, latGS, lonGS)
+	el := link.Elevation
 
-	f := 22.5 // GHz
-	p := 0.1
 	hs := 0.1 // km
-	R001 := pre
-	tau := 45.0
+	if stationPos.Altitude > 0 {
+		hs = stationPos.Altitude / 1000
+	}
+	f, p, tau := radio.Frequency, radio.TimePercentage, radio.Polarization
 	var Ls float64
-	Ar := itur.RainAttenuation(latGS, lonGS, f, el, hs, p, R001, tau, Ls)
-
-	return Ar
+	// 水汽密度、湿度和天线参数使用 itur 中的默认值
+	var D, rho, eta, H, hL, V_t float64
+	L := env.CloudLiquidWater
+	instantaneous := mode == RainRateInstantaneous
+	c := itur.SlantPathContributions(latGS, lonGS, f, el, p, D, hs, rho, R001, eta,
+		env.Temperature2m, H, env.Pressure, hL, Ls, tau, V_t, L,
+		!instantaneous, true, true, true)
+	if instantaneous {
+		c.Rain = itur.RainAttenuation(latGS, lonGS, f, el, hs, 0.01, R001, tau, Ls)
+	}
+	return c.Total()
 }
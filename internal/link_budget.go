@@ -0,0 +1,121 @@
+package demokubenet
+
+import "math"
+
+const (
+	boltzmann = -228.6 // 10log10(k) (dBW/K/Hz)
+
+	// 大气平均辐射温度 (K)，用于由大气衰减估算天空噪声，见 ITU-R P.618
+	meanRadiatingTemperature = 275.0
+)
+
+// Transmitter 卫星下行的发射参数，可以按卫星单独配置
+type Transmitter struct {
+	Power        float64 // 发射功率 (dBW)
+	AntennaGain  float64 // 发射天线增益 (dBi)
+	FeederLoss   float64 // 馈线损耗 (dB)
+	PointingLoss float64 // 发射天线指向损耗 (dB)
+	Frequency    float64 // 载波频率 (GHz)，RadioConfig 未指定频率时使用，为 0 时使用 LinkUpdatePayload 中的频率
+	Bandwidth    float64 // 信道带宽 (Hz)
+	DataRate     float64 // 信息速率 (bit/s)，用于计算 Eb/N0
+}
+
+// Receiver 地面站的接收参数，可以按地面站单独配置
+type Receiver struct {
+	AntennaGain        float64 // 接收天线增益 (dBi)
+	FeederLoss         float64 // 馈线损耗 (dB)
+	PointingLoss       float64 // 接收天线指向损耗 (dB)
+	NoiseTemperature   float64 // 接收机等效噪声温度 (K)
+	AntennaTemperature float64 // 晴空时的天线噪声温度 (K)
+}
+
+func DefaultTransmitter() Transmitter {
+	return Transmitter{
+		Power:        10,
+		AntennaGain:  26,
+		PointingLoss: 0.3,
+		Bandwidth:    250e6,
+		DataRate:     500e6,
+	}
+}
+
+func DefaultReceiver() Receiver {
+	return Receiver{
+		AntennaGain:        33,
+		FeederLoss:         0.5,
+		PointingLoss:       0.3,
+		NoiseTemperature:   150,
+		AntennaTemperature: 30,
+	}
+}
+
+// withDefaults 未配置的发射机使用 DefaultTransmitter，只配置了部分参数时，
+// 不为正的带宽和信息速率取默认值
+func (t Transmitter) withDefaults() Transmitter {
+	def := DefaultTransmitter()
+	if t == (Transmitter{}) {
+		return def
+	}
+	if t.Bandwidth <= 0 {
+		t.Bandwidth = def.Bandwidth
+	}
+	if t.DataRate <= 0 {
+		t.DataRate = def.DataRate
+	}
+	return t
+}
+
+// withDefaults 未配置的接收机使用 DefaultReceiver，只配置了部分参数时，
+// 不为正的接收机噪声温度取默认值，为负的天线噪声温度按 0 处理
+func (r Receiver) withDefaults() Receiver {
+	def := DefaultReceiver()
+	if r == (Receiver{}) {
+		return def
+	}
+	if r.NoiseTemperature <= 0 {
+		r.NoiseTemperature = def.NoiseTemperature
+	}
+	r.AntennaTemperature = math.Max(r.AntennaTemperature, 0)
+	return r
+}
+
+// EIRP 等效全向辐射功率 (dBW)
+func (t Transmitter) EIRP() float64 {
+	return t.Power + t.AntennaGain - t.FeederLoss
+}
+
+// LinkBudget 一条下行链路的预算结果，单位均为 dB 系
+type LinkBudget struct {
+	FSPL float64 // 自由空间路径损耗 (dB)
+	EIRP float64 // 卫星 EIRP (dBW)
+	GT   float64 // 计入大气噪声后的地面站 G/T (dB/K)
+	CN0  float64 // 载噪谱密度比 C/N0 (dBHz)
+	CN   float64 // 信道带宽内的载噪比 C/N (dB)
+	EbN0 float64 // 每比特能量与噪声谱密度比 Eb/N0 (dB)
+}
+
+// FreeSpacePathLoss 自由空间路径损耗 (dB)，d 为距离 (m)，f 为频率 (GHz)
+func FreeSpacePathLoss(d, f float64) float64 {
+	return 20*math.Log10(d/1000) + 20*math.Log10(f) + 92.45
+}
+
+// SystemNoiseTemperature 接收系统噪声温度 (K)，大气衰减 atm (dB) 在削弱晴空天线噪声的同时
+// 引入 Tm(1 - 10^(-A/10)) 的热噪声
+func (r Receiver) SystemNoiseTemperature(atm float64) float64 {
+	trans := math.Pow(10, -atm/10)
+	return r.NoiseTemperature + r.AntennaTemperature*trans + meanRadiatingTemperature*(1-trans)
+}
+
+// CalculateLinkBudget 计算斜距 d (m)、频率 f (GHz)、大气衰减 atm (dB) 下的链路预算，
+// 不为正的带宽、信息速率和噪声温度先按 withDefaults 取默认值，结果中不会出现 Inf 或 NaN
+func CalculateLinkBudget(tx Transmitter, rx Receiver, d, f, atm float64) LinkBudget {
+	tx, rx = tx.withDefaults(), rx.withDefaults()
+	var b LinkBudget
+	b.FSPL = FreeSpacePathLoss(d, f)
+	b.EIRP = tx.EIRP()
+	b.GT = rx.AntennaGain - rx.FeederLoss - 10*math.Log10(rx.SystemNoiseTemperature(atm))
+	b.CN0 = b.EIRP - b.FSPL - atm - tx.PointingLoss - rx.PointingLoss + b.GT - boltzmann
+	b.CN = b.CN0 - 10*math.Log10(tx.Bandwidth)
+	b.EbN0 = b.CN0 - 10*math.Log10(tx.DataRate)
+	return b
+}
@@ -0,0 +1,28 @@
+package demokubenet
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLinkBudgetPartialConfig(t *testing.T) {
+	cases := []struct {
+		name string
+		tx   Transmitter
+		rx   Receiver
+	}{
+		{"zero", Transmitter{}, Receiver{}},
+		{"power only", Transmitter{Power: 13}, Receiver{AntennaGain: 40}},
+		{"negative", Transmitter{Power: 10, Bandwidth: -1, DataRate: -1}, Receiver{NoiseTemperature: -5, AntennaTemperature: -5}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := CalculateLinkBudget(c.tx, c.rx, 1200e3, 20, 3)
+			for name, v := range map[string]float64{"GT": b.GT, "CN0": b.CN0, "CN": b.CN, "EbN0": b.EbN0} {
+				if math.IsInf(v, 0) || math.IsNaN(v) {
+					t.Errorf("%s = %v", name, v)
+				}
+			}
+		})
+	}
+}
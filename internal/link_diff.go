@@ -0,0 +1,76 @@
+package demokubenet
+
+import (
+	"math"
+	"time"
+)
+
+// LinksChangedEvent 每轮链路计算后发布，负载为 LinkDiff，只在有订阅者时发布。
+// 发布不等待队列空位，队列已满时本轮的事件被丢弃并交给死信回调，
+// 按全量调和的订阅者（LinkReconciler.Subscribe）会在下一次事件中补上
+const LinksChangedEvent EventType = "LinksChanged"
+
+// 默认的衰减变化阈值 (dB)，变化不超过该值的链路不作为更新上报
+const DefaultAttenuationThreshold = 0.5
+
+type LinkChangeKind int
+
+const (
+	LinkAdded LinkChangeKind = iota
+	LinkRemoved
+	LinkUpdated
+)
+
+// LinkChange 一条链路的变化，Link 为变化后的链路副本，PrevAr 为上次上报的衰减
+type LinkChange struct {
+	Kind   LinkChangeKind
+	Link   LinkCache
+	PrevAr float64
+}
+
+// LinkDiff 相邻两轮之间的链路变化：新出现的、消失的，以及衰减变化超过阈值
+// 或网络属性（按 LinkProperties 的精度）变化的链路
+type LinkDiff struct {
+	Time    time.Time
+	Added   []LinkChange
+	Removed []LinkChange
+	Updated []LinkChange
+}
+
+func (d *LinkDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Updated) == 0
+}
+
+// diffLinks 对比链路的当前状态与上次上报的状态，并把当前状态记为已上报
+func diffLinks(links []LinkCache, scope linkScope, threshold float64, diff *LinkDiff) {
+	for i := range links {
+		link := &links[i]
+		if !scope.hasLink(link) {
+			continue
+		}
+		switch {
+		case link.Active && !link.reported:
+			diff.Added = append(diff.Added, LinkChange{Kind: LinkAdded, Link: *link})
+		case !link.Active && link.reported:
+			diff.Removed = append(diff.Removed, LinkChange{Kind: LinkRemoved, Link: *link, PrevAr: link.reportedAr})
+		case link.Active && (math.Abs(link.Ar-link.reportedAr) > threshold || link.quanta() != link.reportedQuanta):
+			diff.Updated = append(diff.Updated, LinkChange{Kind: LinkUpdated, Link: *link, PrevAr: link.reportedAr})
+		default:
+			continue
+		}
+		link.reported = link.Active
+		link.reportedAr = link.Ar
+		link.reportedQuanta = linkQuanta{}
+		if link.Active {
+			link.reportedQuanta = link.quanta()
+		}
+	}
+}
+
+// publishLinkDiff 把链路变化发布到调度器，不等待队列空位和订阅者处理完成
+func (e *EmulationInstance) publishLinkDiff(diff LinkDiff) {
+	if diff.Empty() || !e.Scheduler.HasSubscribers(LinksChangedEvent) {
+		return
+	}
+	e.Scheduler.TryPublish(Event{Type: LinksChangedEvent, Time: diff.Time, Payload: diff})
+}
@@ -0,0 +1,98 @@
+package demokubenet
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const SpeedOfLight = 299792458.0 // m/s
+
+// 由载噪比映射网络属性使用的默认参数
+const (
+	DefaultImplementationGap = 3.0  // 实际调制编码与香农极限的差距 (dB)
+	DefaultSNRThreshold      = -2.0 // 最低阶调制编码的解调门限 (dB)
+	DefaultBaseJitter        = 100 * time.Microsecond
+)
+
+// updateNetworkProperties 由斜距和链路预算得到链路的时延、容量、丢包率和抖动：
+// 容量取信道带宽 bandwidth (Hz) 内的香农容量并扣除实现差距，
+// 丢包率在解调门限附近按 logistic 曲线上升，抖动近似为丢包重传带来的时延波动
+func updateNetworkProperties(link *LinkCache, bandwidth float64) {
+	if !link.Active || link.Range <= 0 {
+		link.Budget = LinkBudget{}
+		link.Delay, link.Bandwidth, link.Loss, link.Jitter = 0, 0, 0, 0
+		return
+	}
+	link.Delay = time.Duration(link.Range / SpeedOfLight * float64(time.Second))
+
+	cn := link.Budget.CN
+	snr := math.Pow(10, (cn-DefaultImplementationGap)/10)
+	link.Bandwidth = bandwidth * math.Log2(1+snr)
+
+	lossFraction := 1 / (1 + math.Exp((cn-DefaultSNRThreshold)/0.5))
+	link.Loss = lossFraction * 100
+	link.Jitter = DefaultBaseJitter + time.Duration(2*lossFraction*float64(link.Delay))
+}
+
+// linkQuanta 按 LinkProperties 的精度量化的网络属性，两者相等时格式化结果也相等。
+// 每轮比较它而不是格式化后的字符串，只有变化的链路才需要格式化
+type linkQuanta struct {
+	latency, jitter int64 // 0.1ms
+	loss            int64 // 0.01%
+	// bit/s，不低于 1Mbit 时精确到 1Mbit，不低于 1kbit 时精确到 1kbit
+	rate int64
+}
+
+func (l *LinkCache) quanta() linkQuanta {
+	return linkQuanta{
+		latency: tenthMillis(l.Delay),
+		jitter:  tenthMillis(l.Jitter),
+		loss:    int64(math.Round(l.Loss * 100)),
+		rate:    quantizeRate(l.Bandwidth),
+	}
+}
+
+func tenthMillis(d time.Duration) int64 {
+	return int64(math.Round(float64(d) / float64(100*time.Microsecond)))
+}
+
+func quantizeRate(bps float64) int64 {
+	switch {
+	case bps >= 1e6:
+		return int64(math.Round(bps/1e6)) * 1e6
+	case bps >= 1e3:
+		return int64(math.Round(bps/1e3)) * 1e3
+	}
+	return int64(math.Round(math.Max(bps, 0)))
+}
+
+// Properties 把链路的网络属性转换为 tc netem 风格的 LinkProperties，
+// 时延精确到 0.1ms、速率精确到 1Mbit（低于 1Mbit 时用 kbit 或 bit），避免每轮微小的变化都触发更新
+func (l *LinkCache) Properties() *LinkProperties {
+	return l.quanta().properties()
+}
+
+func (q linkQuanta) properties() *LinkProperties {
+	return &LinkProperties{
+		Latency: formatTenthMillis(q.latency),
+		Jitter:  formatTenthMillis(q.jitter),
+		Loss:    fmt.Sprintf("%d.%02d", q.loss/100, q.loss%100),
+		Rate:    formatRate(q.rate),
+	}
+}
+
+func formatTenthMillis(n int64) string {
+	return fmt.Sprintf("%d.%dms", n/10, n%10)
+}
+
+// formatRate 按 tc 的速率单位格式化，如 624mbit、512kbit、300bit
+func formatRate(bps int64) string {
+	switch {
+	case bps >= 1e6 && bps%1e6 == 0:
+		return fmt.Sprintf("%dmbit", bps/1e6)
+	case bps >= 1e3 && bps%1e3 == 0:
+		return fmt.Sprintf("%dkbit", bps/1e3)
+	}
+	return fmt.Sprintf("%dbit", bps)
+}
@@ -0,0 +1,88 @@
+package demokubenet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLinkProperties(t *testing.T) {
+	cases := []struct {
+		name string
+		link LinkCache
+		want LinkProperties
+	}{
+		{
+			name: "mbit",
+			link: LinkCache{Delay: 3440 * time.Microsecond, Jitter: 100 * time.Microsecond, Loss: 0.004, Bandwidth: 624.4e6},
+			want: LinkProperties{Latency: "3.4ms", Jitter: "0.1ms", Loss: "0.00", Rate: "624mbit"},
+		},
+		{
+			name: "kbit",
+			link: LinkCache{Delay: 25 * time.Millisecond, Jitter: 1260 * time.Microsecond, Loss: 12.3449, Bandwidth: 400.2e3},
+			want: LinkProperties{Latency: "25.0ms", Jitter: "1.3ms", Loss: "12.34", Rate: "400kbit"},
+		},
+		{
+			// 舍入后进位到 1Mbit
+			name: "kbit carry",
+			link: LinkCache{Bandwidth: 999.6e3, Loss: 100},
+			want: LinkProperties{Latency: "0.0ms", Jitter: "0.0ms", Loss: "100.00", Rate: "1mbit"},
+		},
+		{
+			name: "bit",
+			link: LinkCache{Bandwidth: 512.4},
+			want: LinkProperties{Latency: "0.0ms", Jitter: "0.0ms", Loss: "0.00", Rate: "512bit"},
+		},
+		{
+			name: "zero rate",
+			link: LinkCache{},
+			want: LinkProperties{Latency: "0.0ms", Jitter: "0.0ms", Loss: "0.00", Rate: "0bit"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := *c.link.Properties(); got != c.want {
+				t.Errorf("Properties() = %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinksPropertyPrecision(t *testing.T) {
+	sats, stations := testNodes()
+	links := []LinkCache{activeLink(sats[0], stations[0], 3*time.Millisecond)}
+	var diff LinkDiff
+	diffLinks(links, linkScope{}, 1, &diff)
+	if len(diff.Added) != 1 {
+		t.Fatalf("first round: %+v, want one added link", diff)
+	}
+
+	steps := []struct {
+		name    string
+		change  func(*LinkCache)
+		updated bool
+	}{
+		{"below latency precision", func(l *LinkCache) { l.Delay += 40 * time.Microsecond }, false},
+		{"latency", func(l *LinkCache) { l.Delay += 60 * time.Microsecond }, true},
+		{"below rate precision", func(l *LinkCache) { l.Bandwidth += 0.4e6 }, false},
+		{"sub-Mbit rate", func(l *LinkCache) { l.Bandwidth = 300e3 }, true},
+		{"kbit rate", func(l *LinkCache) { l.Bandwidth = 301e3 }, true},
+		{"loss", func(l *LinkCache) { l.Loss = 0.01 }, true},
+	}
+	for _, step := range steps {
+		step.change(&links[0])
+		diff = LinkDiff{}
+		diffLinks(links, linkScope{}, 1, &diff)
+		if got := len(diff.Updated) == 1; got != step.updated {
+			t.Errorf("%s: updated %v, want %v", step.name, got, step.updated)
+		}
+	}
+
+	// 没有变化的链路每轮比较不分配内存
+	allocs := testing.AllocsPerRun(100, func() {
+		diff = LinkDiff{}
+		diffLinks(links, linkScope{}, 1, &diff)
+	})
+	if allocs != 0 {
+		t.Errorf("diffLinks allocates %v times per round for an unchanged link", allocs)
+	}
+}
@@ -0,0 +1,86 @@
+package demokubenet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrLinkExists   = errors.New("link already exists")
+	ErrLinkNotFound = errors.New("link not found")
+)
+
+// LinkSink 接收 Link 增删改的目标，通常是 Kubernetes 中的拓扑资源
+type LinkSink interface {
+	List(ctx context.Context) ([]Link, error)
+	Create(ctx context.Context, link Link) error
+	Update(ctx context.Context, link Link) error
+	Delete(ctx context.Context, link Link) error
+}
+
+// FakeLinkSink 内存中的 LinkSink，行为与 API Server 一致：
+// 重复创建返回 ErrLinkExists，更新或删除不存在的链路返回 ErrLinkNotFound
+type FakeLinkSink struct {
+	mu    sync.Mutex
+	links map[int64]Link
+
+	Creates int
+	Updates int
+	Deletes int
+}
+
+func NewFakeLinkSink() *FakeLinkSink {
+	return &FakeLinkSink{links: make(map[int64]Link)}
+}
+
+func (s *FakeLinkSink) List(ctx context.Context) ([]Link, error) {
+	return s.Links(), nil
+}
+
+func (s *FakeLinkSink) Create(ctx context.Context, link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[link.Uid]; ok {
+		return fmt.Errorf("create link %d: %w", link.Uid, ErrLinkExists)
+	}
+	s.links[link.Uid] = link
+	s.Creates++
+	return nil
+}
+
+func (s *FakeLinkSink) Update(ctx context.Context, link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[link.Uid]; !ok {
+		return fmt.Errorf("update link %d: %w", link.Uid, ErrLinkNotFound)
+	}
+	s.links[link.Uid] = link
+	s.Updates++
+	return nil
+}
+
+func (s *FakeLinkSink) Delete(ctx context.Context, link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[link.Uid]; !ok {
+		return fmt.Errorf("delete link %d: %w", link.Uid, ErrLinkNotFound)
+	}
+	delete(s.links, link.Uid)
+	s.Deletes++
+	return nil
+}
+
+// Links 返回按 Uid 排序的当前链路
+func (s *FakeLinkSink) Links() []Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	links := make([]Link, 0, len(s.links))
+	for _, link := range s.links {
+		links = append(links, link)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Uid < links[j].Uid })
+	return links
+}
@@ -0,0 +1,93 @@
+package demokubenet
+
+// linkKey 链路表中一条星地链路的键
+type linkKey struct {
+	sat     *Satellite
+	station *Station
+}
+
+func keyOf(link *LinkCache) (linkKey, bool) {
+	sat, ok1 := link.SrcNode.(*Satellite)
+	st, ok2 := link.DstNode.(*Station)
+	return linkKey{sat: sat, station: st}, ok1 && ok2
+}
+
+// rebuildLinkIndex 根据 e.Links 重建链路下标索引
+func (e *EmulationInstance) rebuildLinkIndex() {
+	e.linkIndex = make(map[linkKey]int, len(e.Links))
+	for i := range e.Links {
+		if key, ok := keyOf(&e.Links[i]); ok {
+			e.linkIndex[key] = i
+		}
+	}
+}
+
+func (e *EmulationInstance) appendLink(sat *Satellite, st *Station) {
+	e.Links = append(e.Links, LinkCache{
+		SrcNode: sat,
+		DstNode: st,
+	})
+	e.linkIndex[linkKey{sat: sat, station: st}] = len(e.Links) - 1
+}
+
+// removeLinksWhere 删除满足条件的链路，用末尾的链路填补空位，只更新被移动链路的下标。
+// 已上报过的链路记入 pendingRemoved，在下一轮的 LinkDiff 中作为删除上报
+func (e *EmulationInstance) removeLinksWhere(match func(linkKey) bool) int {
+	removed := 0
+	for i := 0; i < len(e.Links); {
+		key, ok := keyOf(&e.Links[i])
+		if !ok || !match(key) {
+			i++
+			continue
+		}
+		delete(e.linkIndex, key)
+		if e.Links[i].reported {
+			removedLink := e.Links[i]
+			removedLink.Active = false
+			e.pendingRemoved = append(e.pendingRemoved, LinkChange{Kind: LinkRemoved, Link: removedLink, PrevAr: removedLink.reportedAr})
+		}
+		last := len(e.Links) - 1
+		if i != last {
+			e.Links[i] = e.Links[last]
+			if moved, ok := keyOf(&e.Links[i]); ok {
+				e.linkIndex[moved] = i
+			}
+		}
+		e.Links[last] = LinkCache{}
+		e.Links = e.Links[:last]
+		removed++
+	}
+	return removed
+}
+
+// Link 返回卫星和地面站之间链路的副本
+func (e *EmulationInstance) Link(sat *Satellite, st *Station) (LinkCache, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	e.linkMu.Lock()
+	defer e.linkMu.Unlock()
+	i, ok := e.linkIndex[linkKey{sat: sat, station: st}]
+	if !ok {
+		return LinkCache{}, false
+	}
+	return e.Links[i], true
+}
+
+// SetLinkRadio 为 sat 与 st 之间的链路单独设置无线参数，cfg 为 nil 时恢复使用地面站的配置，
+// 下一轮链路计算时生效
+func (e *EmulationInstance) SetLinkRadio(sat *Satellite, st *Station, cfg *RadioConfig) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	e.linkMu.Lock()
+	defer e.linkMu.Unlock()
+	i, ok := e.linkIndex[linkKey{sat: sat, station: st}]
+	if !ok {
+		return false
+	}
+	if cfg != nil {
+		c := *cfg
+		cfg = &c
+	}
+	e.Links[i].Radio = cfg
+	return true
+}
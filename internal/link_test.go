@@ -0,0 +1,66 @@
+package demokubenet
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"demokubenet/itur"
+)
+
+// zenithLink 在卫星星下点附近放置一个天气固定的地面站，返回 ts 时刻计算出的链路
+func zenithLink(t *testing.T, ts time.Time, mode RainRateMode, p, rain float64) LinkCache {
+	t.Helper()
+	sats, _ := testNodes()
+	sub := sats[0].GetPosition(ts)
+	st := NewStation(Position{Latitude: sub.Latitude + 1, Longitude: sub.Longitude})
+	st.externalWeather = true
+	st.WeatherIdx = EnvironmentIndex{Temperature2m: 15, Precipitation: rain, Pressure: 1013}
+	st.Radio.TimePercentage = p
+
+	inst, err := newEmulationInstance([]*Station{st}, sats[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.RainRateMode = mode
+	if err := inst.CalculateLinks(ts, LinkUpdatePayload{Frequency: 20}); err != nil {
+		t.Fatal(err)
+	}
+	link := inst.Links[0]
+	if !link.Active || link.Elevation < 45 {
+		t.Fatalf("zenith link active %v at elevation %g", link.Active, link.Elevation)
+	}
+	return link
+}
+
+func TestInstantaneousRainKeepsTimePercentage(t *testing.T) {
+	ts := time.Date(2000, 6, 28, 0, 0, 0, 0, time.UTC)
+
+	// 默认模式下时间百分比仍然影响气体、云和闪烁
+	dry := map[float64]float64{}
+	for _, p := range []float64{0.01, 0.1, 1, 5} {
+		dry[p] = zenithLink(t, ts, RainRateInstantaneous, p, 0).Ar
+	}
+	if dry[0.01] <= dry[0.1] || dry[0.1] <= dry[1] || dry[1] <= dry[5] {
+		t.Errorf("attenuation does not decrease with p: %v", dry)
+	}
+
+	// 当前降雨的衰减不随 p 变化：雨衰始终按 0.01% 计算
+	const rain = 30
+	for _, p := range []float64{0.01, 1} {
+		t.Run(fmt.Sprintf("p=%g", p), func(t *testing.T) {
+			link := zenithLink(t, ts, RainRateInstantaneous, p, rain)
+			st := link.DstNode.(*Station)
+			pos := st.GetPosition(ts)
+			Ar := itur.RainAttenuation(pos.Latitude, pos.Longitude, 20, link.Elevation, 0.1, 0.01, rain, PolarizationCircular, 0)
+			if got := link.Ar - dry[p]; got < 0.9*Ar || got > Ar {
+				t.Errorf("rain adds %g dB at p = %g%%, want close to A0.01(R = %d mm/h) = %g dB", got, p, rain, Ar)
+			}
+		})
+	}
+
+	// 没有读入 P.837 地图时统计模式回退到当前降雨率，结果与默认模式相同
+	if got, want := zenithLink(t, ts, RainRateStatistical, 1, rain).Ar, zenithLink(t, ts, RainRateInstantaneous, 1, rain).Ar; got != want {
+		t.Errorf("statistical mode without maps: %g dB, want %g dB", got, want)
+	}
+}
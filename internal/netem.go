@@ -0,0 +1,190 @@
+package demokubenet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CommandExecutor 执行一条 tc 命令
+type CommandExecutor interface {
+	Exec(ctx context.Context, args []string) error
+}
+
+// ShellExecutor 直接在本机执行命令
+type ShellExecutor struct{}
+
+func (ShellExecutor) Exec(ctx context.Context, args []string) error {
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ScriptExecutor 不执行命令，只把命令逐行写入 W，用于 dry-run 或生成 shell 脚本
+type ScriptExecutor struct {
+	mu sync.Mutex
+	W  io.Writer
+}
+
+// NewDryRunExecutor 把命令打印到标准输出
+func NewDryRunExecutor() *ScriptExecutor {
+	return &ScriptExecutor{W: os.Stdout}
+}
+
+func (e *ScriptExecutor) Exec(ctx context.Context, args []string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := fmt.Fprintln(e.W, strings.Join(args, " "))
+	return err
+}
+
+// NetemArgs 由链路属性生成 netem 参数，如 "delay 3.5ms 0.1ms loss 0.01% rate 624mbit"
+func NetemArgs(p *LinkProperties) []string {
+	if p == nil {
+		return nil
+	}
+	var args []string
+	if p.Latency != "" {
+		args = append(args, "delay", p.Latency)
+		if p.Jitter != "" {
+			args = append(args, p.Jitter)
+			if p.LatencyCorr != "" {
+				args = append(args, p.LatencyCorr+"%")
+			}
+		}
+	}
+	if p.Loss != "" {
+		args = append(args, "loss", p.Loss+"%")
+		if p.LossCorr != "" {
+			args = append(args, p.LossCorr+"%")
+		}
+	}
+	if p.Rate != "" {
+		args = append(args, "rate", p.Rate)
+	}
+	return args
+}
+
+// NetemSink 把 Link 的增删改转换为 tc qdisc 命令的 LinkSink，
+// 每条链路在两端 Pod 中指向对端的接口上配置 root netem qdisc，单向链路只配置 Src 端
+type NetemSink struct {
+	Executor CommandExecutor
+
+	// Device 返回 local 端指向 peer 的接口名，默认为 eth<peer>
+	Device func(local, peer int32) string
+	// Wrap 把命令包装为在 local 端 Pod 中执行，如 kubectl exec 或 ip netns exec，默认不包装
+	Wrap func(namespace string, local int32, args []string) []string
+
+	mu    sync.Mutex
+	links map[int64]Link
+}
+
+func NewNetemSink(executor CommandExecutor) *NetemSink {
+	return &NetemSink{
+		Executor: executor,
+		links:    make(map[int64]Link),
+	}
+}
+
+type netemEnd struct {
+	ns          string
+	local, peer int32
+}
+
+// Commands 返回同步链路变化需要执行的命令：新增链路 tc qdisc add，
+// 更新 tc qdisc change，删除 tc qdisc del
+func (s *NetemSink) Commands(link Link, kind LinkChangeKind) [][]string {
+	ends := []netemEnd{{link.SrcNs, link.Src, link.Dst}}
+	if !link.UniDirectional {
+		ends = append(ends, netemEnd{link.DstNs, link.Dst, link.Src})
+	}
+
+	cmds := make([][]string, 0, len(ends))
+	for _, end := range ends {
+		dev := fmt.Sprintf("eth%d", end.peer)
+		if s.Device != nil {
+			dev = s.Device(end.local, end.peer)
+		}
+		var args []string
+		switch kind {
+		case LinkRemoved:
+			args = []string{"tc", "qdisc", "del", "dev", dev, "root"}
+		case LinkUpdated:
+			args = append([]string{"tc", "qdisc", "change", "dev", dev, "root", "netem"}, NetemArgs(link.Properties)...)
+		default:
+			args = append([]string{"tc", "qdisc", "add", "dev", dev, "root", "netem"}, NetemArgs(link.Properties)...)
+		}
+		if s.Wrap != nil {
+			args = s.Wrap(end.ns, end.local, args)
+		}
+		cmds = append(cmds, args)
+	}
+	return cmds
+}
+
+func (s *NetemSink) apply(ctx context.Context, link Link, kind LinkChangeKind) error {
+	for _, args := range s.Commands(link, kind) {
+		if err := s.Executor.Exec(ctx, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *NetemSink) List(ctx context.Context) ([]Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	links := make([]Link, 0, len(s.links))
+	for _, link := range s.links {
+		links = append(links, link)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Uid < links[j].Uid })
+	return links, nil
+}
+
+func (s *NetemSink) Create(ctx context.Context, link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[link.Uid]; ok {
+		return fmt.Errorf("create link %d: %w", link.Uid, ErrLinkExists)
+	}
+	if err := s.apply(ctx, link, LinkAdded); err != nil {
+		return fmt.Errorf("create link %d: %w", link.Uid, err)
+	}
+	s.links[link.Uid] = link
+	return nil
+}
+
+func (s *NetemSink) Update(ctx context.Context, link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.links[link.Uid]; !ok {
+		return fmt.Errorf("update link %d: %w", link.Uid, ErrLinkNotFound)
+	}
+	if err := s.apply(ctx, link, LinkUpdated); err != nil {
+		return fmt.Errorf("update link %d: %w", link.Uid, err)
+	}
+	s.links[link.Uid] = link
+	return nil
+}
+
+func (s *NetemSink) Delete(ctx context.Context, link Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.links[link.Uid]
+	if !ok {
+		return fmt.Errorf("delete link %d: %w", link.Uid, ErrLinkNotFound)
+	}
+	if err := s.apply(ctx, old, LinkRemoved); err != nil {
+		return fmt.Errorf("delete link %d: %w", link.Uid, err)
+	}
+	delete(s.links, link.Uid)
+	return nil
+}
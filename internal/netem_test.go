@@ -0,0 +1,138 @@
+package demokubenet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetemArgs(t *testing.T) {
+	cases := []struct {
+		name  string
+		props *LinkProperties
+		want  string
+	}{
+		{"nil", nil, ""},
+		{
+			name:  "all",
+			props: (&LinkCache{Delay: 3500 * time.Microsecond, Jitter: 100 * time.Microsecond, Loss: 0.01, Bandwidth: 624e6}).Properties(),
+			want:  "delay 3.5ms 0.1ms loss 0.01% rate 624mbit",
+		},
+		{
+			name:  "zero loss and jitter",
+			props: (&LinkCache{Delay: 12 * time.Millisecond, Bandwidth: 50e6}).Properties(),
+			want:  "delay 12.0ms 0.0ms loss 0.00% rate 50mbit",
+		},
+		{
+			name:  "sub-Mbit rate",
+			props: (&LinkCache{Delay: 270 * time.Millisecond, Jitter: 2 * time.Millisecond, Loss: 2.5, Bandwidth: 256e3}).Properties(),
+			want:  "delay 270.0ms 2.0ms loss 2.50% rate 256kbit",
+		},
+		{
+			name:  "correlation",
+			props: &LinkProperties{Latency: "5ms", Jitter: "1ms", LatencyCorr: "25", Loss: "1", LossCorr: "50"},
+			want:  "delay 5ms 1ms 25% loss 1% 50%",
+		},
+		{
+			// 没有抖动时忽略时延相关系数
+			name:  "correlation without jitter",
+			props: &LinkProperties{Latency: "5ms", LatencyCorr: "25", Rate: "1mbit"},
+			want:  "delay 5ms rate 1mbit",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := strings.Join(NetemArgs(c.props), " "); got != c.want {
+				t.Errorf("NetemArgs() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNetemSinkDryRun(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewNetemSink(&ScriptExecutor{W: &out})
+	sink.Wrap = func(ns string, local int32, args []string) []string {
+		return append([]string{"kubectl", "exec", "-n", ns, fmt.Sprintf("pod-%d", local), "--"}, args...)
+	}
+	ctx := context.Background()
+
+	link := Link{Uid: linkUID(7, 9), Src: 7, Dst: 9, SrcNs: "sat", DstNs: "gs",
+		Properties: (&LinkCache{Delay: 3500 * time.Microsecond, Bandwidth: 624e6}).Properties()}
+	updated := link
+	updated.Properties = (&LinkCache{Delay: 3600 * time.Microsecond, Jitter: 200 * time.Microsecond, Loss: 0.5, Bandwidth: 800e3}).Properties()
+	oneWay := Link{Uid: linkUID(7, 8), Src: 7, Dst: 8, SrcNs: "sat", DstNs: "sat", UniDirectional: true,
+		Properties: (&LinkCache{Delay: 10 * time.Millisecond, Bandwidth: 1e9}).Properties()}
+
+	steps := []struct {
+		name string
+		op   func() error
+		want []string
+	}{
+		{"create", func() error { return sink.Create(ctx, link) }, []string{
+			"kubectl exec -n sat pod-7 -- tc qdisc add dev eth9 root netem delay 3.5ms 0.0ms loss 0.00% rate 624mbit",
+			"kubectl exec -n gs pod-9 -- tc qdisc add dev eth7 root netem delay 3.5ms 0.0ms loss 0.00% rate 624mbit",
+		}},
+		{"update", func() error { return sink.Update(ctx, updated) }, []string{
+			"kubectl exec -n sat pod-7 -- tc qdisc change dev eth9 root netem delay 3.6ms 0.2ms loss 0.50% rate 800kbit",
+			"kubectl exec -n gs pod-9 -- tc qdisc change dev eth7 root netem delay 3.6ms 0.2ms loss 0.50% rate 800kbit",
+		}},
+		{"create unidirectional", func() error { return sink.Create(ctx, oneWay) }, []string{
+			"kubectl exec -n sat pod-7 -- tc qdisc add dev eth8 root netem delay 10.0ms 0.0ms loss 0.00% rate 1000mbit",
+		}},
+		{"delete", func() error { return sink.Delete(ctx, link) }, []string{
+			"kubectl exec -n sat pod-7 -- tc qdisc del dev eth9 root",
+			"kubectl exec -n gs pod-9 -- tc qdisc del dev eth7 root",
+		}},
+		// 失败的操作不输出命令
+		{"delete missing", func() error {
+			if err := sink.Delete(ctx, link); !errors.Is(err, ErrLinkNotFound) {
+				return fmt.Errorf("got %v, want ErrLinkNotFound", err)
+			}
+			return nil
+		}, nil},
+		{"update missing", func() error {
+			if err := sink.Update(ctx, link); !errors.Is(err, ErrLinkNotFound) {
+				return fmt.Errorf("got %v, want ErrLinkNotFound", err)
+			}
+			return nil
+		}, nil},
+	}
+	for _, step := range steps {
+		out.Reset()
+		if err := step.op(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		var got []string
+		if s := strings.TrimSpace(out.String()); s != "" {
+			got = strings.Split(s, "\n")
+		}
+		if strings.Join(got, "\n") != strings.Join(step.want, "\n") {
+			t.Errorf("%s:\n%s\nwant:\n%s", step.name, strings.Join(got, "\n"), strings.Join(step.want, "\n"))
+		}
+	}
+
+	links, _ := sink.List(ctx)
+	if len(links) != 1 || links[0].Uid != oneWay.Uid {
+		t.Errorf("sink lists %+v, want only the unidirectional link", links)
+	}
+}
+
+func TestNetemSinkDevice(t *testing.T) {
+	sink := NewNetemSink(NewDryRunExecutor())
+	sink.Device = func(local, peer int32) string { return fmt.Sprintf("veth-%d-%d", local, peer) }
+	cmds := sink.Commands(Link{Src: 1, Dst: 2}, LinkRemoved)
+	want := []string{"tc qdisc del dev veth-1-2 root", "tc qdisc del dev veth-2-1 root"}
+	if len(cmds) != len(want) {
+		t.Fatalf("%d commands, want %d", len(cmds), len(want))
+	}
+	for i := range cmds {
+		if got := strings.Join(cmds[i], " "); got != want[i] {
+			t.Errorf("command %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"math"
 	"time"
 
+	"demokubenet/itur"
+
 	"github.com/joshuaferrara/go-satellite"
 )
 
@@ -11,6 +13,8 @@ const DELTA_TIME = 100 * time.Millisecond
 
 type Node interface {
 	GetPosition(timestamp time.Time) Position
+	// GetVelocity 返回 timestamp 时刻相对地球 (ECEF) 的速度 (m/s)，所有节点使用同一坐标系，
+	// 两端速度相减即为相对速度
 	GetVelocity(timestamp time.Time) Velocity
 }
 
@@ -20,12 +24,47 @@ type Satellite struct {
 	TleLine2      string
 	SGP4Satellite satellite.Satellite
 	Position      Position
+	// 与 Position 同一时刻的 ECEF 运动状态，用于计算多普勒
+	state ecefState
+	// 与 Position 同一时刻的轨道面参数，用于生成星间链路
+	orbit orbitState
+	// 下行发射参数
+	Transmitter Transmitter
+}
+
+func NewSatellite(line1, line2 string) *Satellite {
+	return &Satellite{
+		TleLine1:      line1,
+		TleLine2:      line2,
+		SGP4Satellite: satellite.TLEToSat(line1, line2, satellite.GravityWGS72),
+		Transmitter:   DefaultTransmitter(),
+	}
 }
 
+// 默认最低仰角 (度)，低于该仰角的卫星不可见
+const DefaultMinElevation = 10.0
+
 type Station struct {
 	Node
-	position   Position
+	position Position
+	// 创建时的位置，移动终端为轨迹起点，用作稳定标识，见 NodeKey
+	home       Position
 	WeatherIdx EnvironmentIndex
+	// 仰角掩模 (度)，只有仰角不低于该值的卫星才能建立链路
+	MinElevation float64
+	// 接收参数
+	Receiver Receiver
+	// 终端的无线参数
+	Radio RadioConfig
+	// 终端类型，移动终端按 Trajectory 运动，每轮天气步骤开始时更新位置
+	Type       TerminalType
+	Trajectory *Trajectory
+	// 天气由 WeatherChangedEvent 提供时不再使用模拟数据覆盖
+	externalWeather bool
+	// P.837 的 R0.01 只与位置有关，在天气步骤中按位置缓存
+	rainRatePos Position
+	rainRate001 float64
+	rainRateOK  bool
 }
 
 type Position struct {
@@ -40,26 +79,116 @@ type Velocity struct {
 	Z float64
 }
 
+func NewStation(pos Position) *Station {
+	return &Station{
+		position:     pos,
+		home:         pos,
+		MinElevation: DefaultMinElevation,
+		Receiver:     DefaultReceiver(),
+		Radio:        DefaultRadioConfig(),
+	}
+}
+
+// RainRate001 站点处 0.01% 时间被超过的降雨率 (mm/h)，见 itu837。
+// 只在 RainRateStatistical 模式下由天气步骤更新，与站点位置在同一轮中计算；
+// 没有读入 P.837 地图时 ok 为 false
+func (s *Station) RainRate001() (R001 float64, ok bool) {
+	return s.rainRate001, s.rainRateOK
+}
+
+// updateRainRate001 位置变化后重新计算 R0.01，调用方持有 weatherMu。
+// 没有读入 P.837 地图时返回 false
+func (s *Station) updateRainRate001() bool {
+	if s.rainRateOK && s.rainRatePos == s.position {
+		return true
+	}
+	R001, ok := itur.RainfallRate001(s.position.Latitude, s.position.Longitude)
+	s.rainRatePos, s.rainRate001, s.rainRateOK = s.position, R001, ok
+	return ok
+}
+
+// GetVelocity 返回 ECEF 速度 (m/s)，固定地面站相对地球静止，速度为 0
+func (s *Station) GetVelocity(timestamp time.Time) Velocity {
+	if s.Trajectory == nil {
+		return Velocity{}
+	}
+	_, v := s.Trajectory.At(timestamp)
+	return v
+}
+
+// stateAt 返回 timestamp 时刻的位置和 ECEF 速度，固定地面站返回当前位置
+func (s *Station) stateAt(timestamp time.Time) (Position, Velocity) {
+	if s.Trajectory == nil {
+		return s.position, Velocity{}
+	}
+	return s.Trajectory.At(timestamp)
+}
+
 func (s *Station) GetPosition(timestamp time.Time) Position {
 	// log.Println("Station GetPosition")
+	if s.Trajectory != nil {
+		pos, _ := s.Trajectory.At(timestamp)
+		return pos
+	}
 	return s.position
 }
 
-func (s *Satellite) GetVelocity(timestamp time.Time) Velocity {
-	t1 := time.Now().UTC()
-	t2 := t1.Add(DELTA_TIME)
+// 地球自转角速度 (rad/s)
+const EarthRotationRate = 7.292115e-5
 
-	lla1 := s.GetPosition(t1)
-	lla2 := s.GetPosition(t2)
+// 地心引力常数 (km^3/s^2)
+const earthMu = 398600.4418
 
-	ecef1 := LLAtoECEF(lla1)
-	ecef2 := LLAtoECEF(lla2)
+// julianDate 返回 t 对应的儒略日，保留秒以下的部分
+func julianDate(t time.Time) float64 {
+	return 2440587.5 + float64(t.UnixNano())/float64(24*time.Hour)
+}
+
+// greenwichSiderealTime 儒略日 jd 对应的格林尼治恒星时 (rad)，与 go-satellite 的 gstime 相同 (IAU-82)
+func greenwichSiderealTime(jd float64) float64 {
+	tut1 := (jd - 2451545.0) / 36525.0
+	temp := -6.2e-6*tut1*tut1*tut1 + 0.093104*tut1*tut1 + (876600.0*3600+8640184.812866)*tut1 + 67310.54841
+	temp = math.Mod(temp*math.Pi/180/240.0, 2*math.Pi)
+	if temp < 0 {
+		temp += 2 * math.Pi
+	}
+	return temp
+}
+
+// propagate 返回 timestamp 时刻 SGP4 输出的惯性系 (TEME) 位置 (km)、速度 (km/s) 和格林尼治恒星时 (rad)。
+// satellite.Propagate 只接受整秒，秒以下的部分 dt 从整秒的状态按二体加速度外推：
+// r = r0 + v0·dt + a·dt²/2，v = v0 + a·dt，a = -μ·r0/|r0|³，dt < 1 s 时误差在毫米量级
+func (s *Satellite) propagate(timestamp time.Time) (satellite.Vector3, satellite.Vector3, float64) {
+	t := timestamp.UTC()
+	t0 := t.Truncate(time.Second)
+	r, v := satellite.Propagate(s.SGP4Satellite, t0.Year(), int(t0.Month()), t0.Day(), t0.Hour(), t0.Minute(), t0.Second())
 
-	return Velocity{
-		X: (ecef2[0] - ecef1[0]) / DELTA_TIME.Seconds(),
-		Y: (ecef2[1] - ecef1[1]) / DELTA_TIME.Seconds(),
-		Z: (ecef2[2] - ecef1[2]) / DELTA_TIME.Seconds(),
+	if dt := t.Sub(t0).Seconds(); dt > 0 {
+		norm := math.Sqrt(r.X*r.X + r.Y*r.Y + r.Z*r.Z)
+		k := -earthMu / (norm * norm * norm)
+		ax, ay, az := k*r.X, k*r.Y, k*r.Z
+		r.X += v.X*dt + ax*dt*dt/2
+		r.Y += v.Y*dt + ay*dt*dt/2
+		r.Z += v.Z*dt + az*dt*dt/2
+		v.X += ax * dt
+		v.Y += ay * dt
+		v.Z += az * dt
 	}
+	return r, v, greenwichSiderealTime(julianDate(t))
+}
+
+// GetVelocity 返回 timestamp 时刻相对地球 (ECEF) 的速度 (m/s)，
+// 即 v_ecef = R(gmst)·v_eci - ω × r_ecef，与 Station.GetVelocity 的坐标系相同
+func (s *Satellite) GetVelocity(timestamp time.Time) Velocity {
+	r, v, gmst := s.propagate(timestamp)
+	state := newECEFState(r, v, gmst)
+	return Velocity{X: state.V[0], Y: state.V[1], Z: state.V[2]}
+}
+
+// GetVelocityTEME 返回 timestamp 时刻 SGP4 输出的惯性系 (TEME) 速度 (m/s)
+func (s *Satellite) GetVelocityTEME(timestamp time.Time) Velocity {
+	_, v, _ := s.propagate(timestamp)
+	return Velocity{X: v.X * 1000, Y: v.Y * 1000, Z: v.Z * 1000}
 }
 
 func (s *Satellite) GetPosition(timestamp time.Time) Position {
@@ -67,16 +196,28 @@ func (s *Satellite) GetPosition(timestamp time.Time) Position {
 	// currentTime := time.Now().UTC()
 	// year, month, day := currentTime.Date()
 	// hour, min, sec := currentTime.Clock()
-	position, _ := satellite.Propagate(s.SGP4Satellite, timestamp.Year(), int(timestamp.Month()), timestamp.Day(), timestamp.Hour(), timestamp.Minute(), timestamp.Second())
-	// 转换到地理坐标
-	gmst := satellite.GSTimeFromDate(timestamp.Year(), int(timestamp.Month()), timestamp.Day(), timestamp.Hour(), timestamp.Minute(), timestamp.Second())
+	position, _, gmst := s.propagate(timestamp)
+	return eciToPosition(position, gmst)
+}
+
+// updateState 用一次传播同时更新经纬高位置、ECEF 运动状态和轨道面参数
+func (s *Satellite) updateState(timestamp time.Time) {
+	r, v, gmst := s.propagate(timestamp)
+	s.Position = eciToPosition(r, gmst)
+	s.state = newECEFState(r, v, gmst)
+	s.orbit = newOrbitState(r, v)
+}
+
+// eciToPosition 把惯性系位置 (km) 转换到地理坐标
+func eciToPosition(position satellite.Vector3, gmst float64) Position {
 	alt, _, lla := satellite.ECIToLLA(position, gmst)
 
 	// 单位转换
 	latitudeDeg := lla.Latitude * 180 / math.Pi
 	longitudeDeg := lla.Longitude * 180 / math.Pi
 
-	longitudeDeg = math.Mod(longitudeDeg+180+360, 360)
+	// ECIToLLA 的经度在 (-540, 180] 度之间，归一化到 [-180, 180)
+	longitudeDeg = math.Mod(longitudeDeg+540, 360) - 180
 
 	altitudeMeters := alt * 1000
 
@@ -87,6 +228,34 @@ func (s *Satellite) GetPosition(timestamp time.Time) Position {
 	}
 }
 
+// ecefState 节点在 ECEF 中的位置 (m)、速度 (m/s) 和加速度 (m/s²)
+type ecefState struct {
+	R, V, A [3]float64
+}
+
+// newECEFState 由惯性系位置 (km)、速度 (km/s) 得到 ECEF 运动状态，加速度只计二体引力：
+// v_ecef = R·v_eci - ω×r_ecef，a_ecef = R·a_eci - 2ω×v_ecef - ω×(ω×r_ecef)
+func newECEFState(r, v satellite.Vector3, gmst float64) ecefState {
+	const w = EarthRotationRate
+	norm := math.Sqrt(r.X*r.X + r.Y*r.Y + r.Z*r.Z)
+	k := -earthMu / (norm * norm * norm)
+	a := satellite.Vector3{X: k * r.X, Y: k * r.Y, Z: k * r.Z}
+
+	rr := satellite.ECIToECEF(r, gmst)
+	vr := satellite.ECIToECEF(v, gmst)
+	ar := satellite.ECIToECEF(a, gmst)
+
+	var st ecefState
+	st.R = [3]float64{rr.X * 1000, rr.Y * 1000, rr.Z * 1000}
+	st.V = [3]float64{(vr.X + w*rr.Y) * 1000, (vr.Y - w*rr.X) * 1000, vr.Z * 1000}
+	st.A = [3]float64{
+		ar.X*1000 + 2*w*st.V[1] + w*w*st.R[0],
+		ar.Y*1000 - 2*w*st.V[0] + w*w*st.R[1],
+		ar.Z * 1000,
+	}
+	return st
+}
+
 const (
 	A  = 6378137.0        // WGS84 长半轴
 	E2 = 6.69437999014e-3 // 第一偏心率的平方
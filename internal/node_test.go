@@ -0,0 +1,119 @@
+package demokubenet
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// satelliteEpochs 测试卫星 TLE 历元附近的时刻
+var satelliteEpochs = []time.Time{
+	time.Date(2000, 6, 28, 3, 0, 0, 0, time.UTC),
+	time.Date(2004, 2, 1, 3, 0, 0, 0, time.UTC),
+}
+
+func sub3(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func norm3(a [3]float64) float64 {
+	return math.Sqrt(dot(a, a))
+}
+
+// derivative 五点中心差分 (-f(2h) + 8f(h) - 8f(-h) + f(-2h)) / 12h
+func derivative(f func(time.Time) [3]float64, ts time.Time, h time.Duration) [3]float64 {
+	p1, m1 := f(ts.Add(h)), f(ts.Add(-h))
+	p2, m2 := f(ts.Add(2*h)), f(ts.Add(-2*h))
+	var d [3]float64
+	for k := range d {
+		d[k] = (-p2[k] + 8*p1[k] - 8*m1[k] + m2[k]) / (12 * h.Seconds())
+	}
+	return d
+}
+
+func TestSatelliteVelocityMatchesPosition(t *testing.T) {
+	sats, _ := testNodes()
+	for i, sat := range sats {
+		for _, offset := range []time.Duration{0, 17 * time.Minute, 3 * time.Hour} {
+			ts := satelliteEpochs[i].Add(offset)
+			t.Run(fmt.Sprintf("sat%d+%v", i, offset), func(t *testing.T) {
+				// SGP4 的时刻按儒略日计算，分辨率约 40 µs，位置有亚米级噪声，所以用 10 s 步长的五点差分。
+				// 库输出的速度与位置的导数本身相差约 1e-4（偏心轨道上约 1 m/s），容差取相对 2e-4；
+				// 坐标系用错时相差的是地球自转速度 ω×r（数百 m/s）
+				const h, rel = 10 * time.Second, 2e-4
+				ecef := func(ts time.Time) [3]float64 { return LLAtoECEF(sat.GetPosition(ts)) }
+				fd := derivative(ecef, ts, h)
+				v := sat.GetVelocity(ts)
+				if d := norm3(sub3([3]float64{v.X, v.Y, v.Z}, fd)); d > rel*norm3(fd) {
+					t.Errorf("ECEF velocity %+v, finite difference %v, |diff| %g m/s", v, fd, d)
+				}
+
+				teme := func(ts time.Time) [3]float64 {
+					r, _, _ := sat.propagate(ts)
+					return [3]float64{r.X * 1000, r.Y * 1000, r.Z * 1000}
+				}
+				fd = derivative(teme, ts, h)
+				v = sat.GetVelocityTEME(ts)
+				if d := norm3(sub3([3]float64{v.X, v.Y, v.Z}, fd)); d > rel*norm3(fd) {
+					t.Errorf("TEME velocity %+v, finite difference %v, |diff| %g m/s", v, fd, d)
+				}
+			})
+		}
+	}
+}
+
+func TestNodeVelocityFrames(t *testing.T) {
+	sats, _ := testNodes()
+	ts := satelliteEpochs[0]
+	sat := sats[0]
+
+	// ECEF 与惯性系速度相差地球自转：|v_teme| 与 |v_ecef + ω×r| 相等
+	r := LLAtoECEF(sat.GetPosition(ts))
+	v := sat.GetVelocity(ts)
+	inertial := [3]float64{v.X - EarthRotationRate*r[1], v.Y + EarthRotationRate*r[0], v.Z}
+	vt := sat.GetVelocityTEME(ts)
+	if got, want := norm3(inertial), norm3([3]float64{vt.X, vt.Y, vt.Z}); math.Abs(got-want) > 1 {
+		t.Errorf("|v_ecef + ω×r| = %g m/s, |v_teme| = %g m/s", got, want)
+	}
+
+	// 固定地面站在 ECEF 中静止，不随地球自转产生速度
+	var node Node = NewStation(Position{Latitude: 30, Longitude: 120})
+	if v := node.GetVelocity(ts); v != (Velocity{}) {
+		t.Errorf("fixed station velocity %+v, want zero", v)
+	}
+}
+
+func TestSubSecondPropagation(t *testing.T) {
+	sats, _ := testNodes()
+	for i, sat := range sats {
+		t.Run(fmt.Sprintf("sat%d", i), func(t *testing.T) {
+			// 跨过整秒时位置连续：整秒前 1 ns 外推得到的位置与整秒的 SGP4 结果只差噪声量级
+			// （SGP4 的时刻分辨率约 40 µs，对应亚米级的噪声）；只传整秒时两者相差约一秒的路程
+			const tol = 1.0 // m
+			start := satelliteEpochs[i].Add(5 * time.Minute)
+			for s := 1; s <= 5; s++ {
+				boundary := start.Add(time.Duration(s) * time.Second)
+				before := LLAtoECEF(sat.GetPosition(boundary.Add(-time.Nanosecond)))
+				at := LLAtoECEF(sat.GetPosition(boundary))
+				if d := norm3(sub3(at, before)); d > tol {
+					t.Errorf("position jumps %g m across %v", d, boundary.Format(time.RFC3339Nano))
+				}
+			}
+
+			// 100 ms 一步，每步的位移与速度一致
+			const step = 100 * time.Millisecond
+			prev := LLAtoECEF(sat.GetPosition(start))
+			for k := 1; k <= 20; k++ {
+				ts := start.Add(time.Duration(k) * step)
+				cur := LLAtoECEF(sat.GetPosition(ts))
+				v := sat.GetVelocity(ts.Add(-step / 2))
+				want := norm3([3]float64{v.X, v.Y, v.Z}) * step.Seconds()
+				if got := norm3(sub3(cur, prev)); math.Abs(got-want) > tol {
+					t.Errorf("step %d moves %g m, want %g m", k, got, want)
+				}
+				prev = cur
+			}
+		})
+	}
+}
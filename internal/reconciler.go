@@ -0,0 +1,301 @@
+package demokubenet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+)
+
+// PodIdentity 仿真节点对应的 Pod，ID 填入 Link.Src / Link.Dst
+type PodIdentity struct {
+	ID        int32
+	Name      string
+	Namespace string
+}
+
+// NodeMapper 把卫星和地面站映射到 Pod
+type NodeMapper interface {
+	Identity(node Node) (PodIdentity, error)
+}
+
+// NodeReleaser 在节点被移除后释放它占用的 ID
+type NodeReleaser interface {
+	Release(node Node)
+}
+
+// NodeKey 节点的稳定标识：卫星为 TLE 中的卫星编号，地面站为终端类型和初始位置
+func NodeKey(node Node) (string, error) {
+	switch n := node.(type) {
+	case *Satellite:
+		if len(n.TleLine1) >= 7 {
+			return "sat/" + strings.TrimSpace(n.TleLine1[2:7]), nil
+		}
+		return "sat/" + n.TleLine1, nil
+	case *Station:
+		p := n.home
+		return fmt.Sprintf("gs/%s/%.6f/%.6f/%.1f", n.Type, p.Latitude, p.Longitude, p.Altitude), nil
+	}
+	return "", fmt.Errorf("unsupported node type %T", node)
+}
+
+// IndexMapper 由 NodeKey 的哈希分配 ID，哈希冲突时顺延到下一个空闲的 ID。
+// 没有冲突时同一节点在不同运行、不同加入顺序下得到相同的 ID；有冲突时先加入的节点占用哈希位置，
+// 冲突节点的 ID 取决于加入和释放的顺序。卫星命名为 sat-<id>，地面站命名为 gs-<id>
+type IndexMapper struct {
+	SatelliteNamespace string
+	StationNamespace   string
+
+	mu     sync.Mutex
+	ids    map[Node]PodIdentity
+	owners map[int32]Node
+}
+
+func NewIndexMapper(satelliteNamespace, stationNamespace string) *IndexMapper {
+	return &IndexMapper{
+		SatelliteNamespace: satelliteNamespace,
+		StationNamespace:   stationNamespace,
+		ids:                make(map[Node]PodIdentity),
+		owners:             make(map[int32]Node),
+	}
+}
+
+func (m *IndexMapper) Identity(node Node) (PodIdentity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, ok := m.ids[node]; ok {
+		return id, nil
+	}
+	key, err := NodeKey(node)
+	if err != nil {
+		return PodIdentity{}, err
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	n := int32(h.Sum32() & math.MaxInt32)
+	for n == 0 || m.owners[n] != nil {
+		n = (n + 1) & math.MaxInt32
+	}
+
+	var id PodIdentity
+	if _, ok := node.(*Satellite); ok {
+		id = PodIdentity{ID: n, Name: fmt.Sprintf("sat-%d", n), Namespace: m.SatelliteNamespace}
+	} else {
+		id = PodIdentity{ID: n, Name: fmt.Sprintf("gs-%d", n), Namespace: m.StationNamespace}
+	}
+	m.ids[node] = id
+	m.owners[n] = node
+	return id, nil
+}
+
+// Release 释放节点的 ID，之后再次出现的同一节点重新按 NodeKey 分配
+func (m *IndexMapper) Release(node Node) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, ok := m.ids[node]; ok {
+		delete(m.ids, node)
+		delete(m.owners, id.ID)
+	}
+}
+
+// ReconcileResult 一次调和中对 LinkSink 执行的操作数
+type ReconcileResult struct {
+	Created int
+	Updated int
+	Deleted int
+}
+
+// LinkReconciler 根据链路表生成期望的 Link 集合并同步到 LinkSink
+type LinkReconciler struct {
+	Mapper NodeMapper
+	Sink   LinkSink
+
+	mu sync.Mutex
+	// 已同步到 Sink 的链路，按两端节点索引。删除链路时使用这里的 Uid 而不再查询 Mapper：
+	// 节点移除后 ID 已被释放，重新查询会分配新的 ID，冲突时还可能得到别的节点的 ID
+	applied map[[2]Node]Link
+}
+
+func NewLinkReconciler(mapper NodeMapper, sink LinkSink) *LinkReconciler {
+	return &LinkReconciler{Mapper: mapper, Sink: sink}
+}
+
+// linkUID 由两端的 ID 组成链路的 Uid
+func linkUID(src, dst int32) int64 {
+	return int64(src)<<32 | int64(uint32(dst))
+}
+
+// ToLink 把一条链路转换为 Link，星地链路的卫星为 Src、地面站为 Dst，星间链路沿用 LinkCache 中的两端
+func (r *LinkReconciler) ToLink(link *LinkCache) (Link, error) {
+	src, err := r.Mapper.Identity(link.SrcNode)
+	if err != nil {
+		return Link{}, err
+	}
+	dst, err := r.Mapper.Identity(link.DstNode)
+	if err != nil {
+		return Link{}, err
+	}
+	return Link{
+		Uid:        linkUID(src.ID, dst.ID),
+		Src:        src.ID,
+		Dst:        dst.ID,
+		Properties: link.Properties(),
+		SrcNs:      src.Namespace,
+		DstNs:      dst.Namespace,
+	}, nil
+}
+
+// linksEqual 比较两条 Link 的内容，Properties 按值比较
+func linksEqual(a, b Link) bool {
+	pa, pb := a.Properties, b.Properties
+	a.Properties, b.Properties = nil, nil
+	if a != b {
+		return false
+	}
+	if pa == nil || pb == nil {
+		return pa == pb
+	}
+	return *pa == *pb
+}
+
+// Desired 返回所有 Active 链路对应的 Link
+func (r *LinkReconciler) Desired(links []LinkCache) ([]Link, error) {
+	var desired []Link
+	for i := range links {
+		if !links[i].Active {
+			continue
+		}
+		link, err := r.ToLink(&links[i])
+		if err != nil {
+			return nil, err
+		}
+		desired = append(desired, link)
+	}
+	return desired, nil
+}
+
+// Reconcile 把 LinkSink 中的链路调和为 links 中的 Active 链路：
+// 缺少的创建，内容不同的更新，多余的删除。单个操作失败不影响其他操作，错误合并返回
+func (r *LinkReconciler) Reconcile(ctx context.Context, links []LinkCache) (ReconcileResult, error) {
+	var result ReconcileResult
+	desired, err := r.Desired(links)
+	if err != nil {
+		return result, err
+	}
+	current, err := r.Sink.List(ctx)
+	if err != nil {
+		return result, err
+	}
+	existing := make(map[int64]Link, len(current))
+	for _, link := range current {
+		existing[link.Uid] = link
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applied = make(map[[2]Node]Link, len(desired))
+	var errs []error
+	k := 0
+	for i := range links {
+		if !links[i].Active {
+			continue
+		}
+		link := desired[k]
+		k++
+		pair := [2]Node{links[i].SrcNode, links[i].DstNode}
+		old, ok := existing[link.Uid]
+		delete(existing, link.Uid)
+		switch {
+		case !ok:
+			if err := r.Sink.Create(ctx, link); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			result.Created++
+		case !linksEqual(old, link):
+			if err := r.Sink.Update(ctx, link); err != nil {
+				r.applied[pair] = old
+				errs = append(errs, err)
+				continue
+			}
+			result.Updated++
+		}
+		r.applied[pair] = link
+	}
+	for _, link := range existing {
+		if err := r.Sink.Delete(ctx, link); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.Deleted++
+	}
+	return result, errors.Join(errs...)
+}
+
+// ApplyDiff 只把一轮的链路变化同步到 LinkSink，不需要列出全部链路。
+// 消失的链路按之前同步时的 Uid 删除，两端节点此时可能已经释放了 ID
+func (r *LinkReconciler) ApplyDiff(ctx context.Context, diff LinkDiff) (ReconcileResult, error) {
+	var result ReconcileResult
+	var errs []error
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.applied == nil {
+		r.applied = make(map[[2]Node]Link)
+	}
+
+	for i := range diff.Removed {
+		cache := &diff.Removed[i].Link
+		pair := [2]Node{cache.SrcNode, cache.DstNode}
+		link, ok := r.applied[pair]
+		if !ok {
+			src, _ := NodeKey(cache.SrcNode)
+			dst, _ := NodeKey(cache.DstNode)
+			errs = append(errs, fmt.Errorf("remove link %s-%s: never applied: %w", src, dst, ErrLinkNotFound))
+			continue
+		}
+		if err := r.Sink.Delete(ctx, link); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(r.applied, pair)
+		result.Deleted++
+	}
+
+	apply := func(changes []LinkChange, op func(context.Context, Link) error, count *int) {
+		for i := range changes {
+			cache := &changes[i].Link
+			link, err := r.ToLink(cache)
+			if err == nil {
+				err = op(ctx, link)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			r.applied[[2]Node{cache.SrcNode, cache.DstNode}] = link
+			*count++
+		}
+	}
+	apply(diff.Added, r.Sink.Create, &result.Created)
+	apply(diff.Updated, r.Sink.Update, &result.Updated)
+	return result, errors.Join(errs...)
+}
+
+// Subscribe 订阅实例的 LinksChangedEvent，链路有变化时按实例当前的 Active 链路（含星间链路）调和。
+// 相邻两轮的事件可能并发处理，按全量调和而不是逐条应用 LinkDiff，结果与处理顺序无关。
+// Mapper 实现 NodeReleaser 时，节点从实例中移除后释放它的 ID
+func (r *LinkReconciler) Subscribe(inst *EmulationInstance) SubscriptionID {
+	if rel, ok := r.Mapper.(NodeReleaser); ok {
+		inst.OnNodeRemoved(rel.Release)
+	}
+	var mu sync.Mutex
+	return inst.Scheduler.Subscribe(LinksChangedEvent, func(eb *EventBus, event Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := r.Reconcile(eb.Context(), append(inst.ActiveLinks(), inst.ActiveISLs()...))
+		return err
+	})
+}
@@ -0,0 +1,262 @@
+package demokubenet
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"testing"
+	"time"
+)
+
+var testTLEs = [][2]string{
+	{"1 00005U 58002B   00179.78495062  .00000023  00000-0  28098-4 0  4753",
+		"2 00005  34.2682 348.7242 1859667 331.7664  19.3264 10.82419157413667"},
+	{"1 04632U 70093B   04031.91070959 -.00000084  00000-0  10000-3 0  9955",
+		"2 04632  11.4628 273.1101 1450506 207.6000 143.9350  1.20231981 44145"},
+}
+
+func testNodes() ([]*Satellite, []*Station) {
+	var sats []*Satellite
+	for _, tle := range testTLEs {
+		sats = append(sats, NewSatellite(tle[0], tle[1]))
+	}
+	stations := []*Station{
+		NewStation(Position{Latitude: 31.2304, Longitude: 121.4737}),
+		NewStation(Position{Latitude: 39.9042, Longitude: 116.4074}),
+	}
+	return sats, stations
+}
+
+func activeLink(sat *Satellite, st *Station, delay time.Duration) LinkCache {
+	return LinkCache{SrcNode: sat, DstNode: st, Active: true, Delay: delay, Bandwidth: 100e6}
+}
+
+func TestReconcileFakeLinkSink(t *testing.T) {
+	sats, stations := testNodes()
+	sink := NewFakeLinkSink()
+	r := NewLinkReconciler(NewIndexMapper("sat", "gs"), sink)
+	ctx := context.Background()
+
+	links := []LinkCache{
+		activeLink(sats[0], stations[0], 3*time.Millisecond),
+		activeLink(sats[1], stations[1], 5*time.Millisecond),
+		{SrcNode: sats[0], DstNode: stations[1]},
+	}
+
+	t.Run("create", func(t *testing.T) {
+		result, err := r.Reconcile(ctx, links)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != (ReconcileResult{Created: 2}) || len(sink.Links()) != 2 {
+			t.Fatalf("result %+v, sink has %d links", result, len(sink.Links()))
+		}
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		result, err := r.Reconcile(ctx, links)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != (ReconcileResult{}) {
+			t.Fatalf("result %+v, want no operations", result)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		links[0].Delay = 4 * time.Millisecond
+		result, err := r.Reconcile(ctx, links)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != (ReconcileResult{Updated: 1}) {
+			t.Fatalf("result %+v, want 1 update", result)
+		}
+		want, _ := r.ToLink(&links[0])
+		for _, link := range sink.Links() {
+			if link.Uid == want.Uid && link.Properties.Latency != "4.0ms" {
+				t.Fatalf("latency %s, want 4.0ms", link.Properties.Latency)
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		links[1].Active = false
+		result, err := r.Reconcile(ctx, links)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != (ReconcileResult{Deleted: 1}) || len(sink.Links()) != 1 {
+			t.Fatalf("result %+v, sink has %d links", result, len(sink.Links()))
+		}
+	})
+}
+
+func TestApplyDiffFakeLinkSink(t *testing.T) {
+	sats, stations := testNodes()
+	sink := NewFakeLinkSink()
+	r := NewLinkReconciler(NewIndexMapper("sat", "gs"), sink)
+	ctx := context.Background()
+	a := activeLink(sats[0], stations[0], 3*time.Millisecond)
+	b := activeLink(sats[1], stations[0], 6*time.Millisecond)
+
+	steps := []struct {
+		name string
+		diff LinkDiff
+		want ReconcileResult
+		left int
+	}{
+		{"add", LinkDiff{Added: []LinkChange{{Kind: LinkAdded, Link: a}, {Kind: LinkAdded, Link: b}}}, ReconcileResult{Created: 2}, 2},
+		{"update", LinkDiff{Updated: []LinkChange{{Kind: LinkUpdated, Link: a}}}, ReconcileResult{Updated: 1}, 2},
+		{"remove", LinkDiff{Removed: []LinkChange{{Kind: LinkRemoved, Link: b}}}, ReconcileResult{Deleted: 1}, 1},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			result, err := r.ApplyDiff(ctx, step.diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != step.want || len(sink.Links()) != step.left {
+				t.Fatalf("result %+v, sink has %d links; want %+v and %d", result, len(sink.Links()), step.want, step.left)
+			}
+		})
+	}
+}
+
+func TestFakeLinkSinkErrors(t *testing.T) {
+	ctx := context.Background()
+	sink := NewFakeLinkSink()
+	link := Link{Uid: 1, Src: 1, Dst: 2}
+
+	if err := sink.Update(ctx, link); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("update missing link: %v, want ErrLinkNotFound", err)
+	}
+	if err := sink.Delete(ctx, link); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("delete missing link: %v, want ErrLinkNotFound", err)
+	}
+	if err := sink.Create(ctx, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Create(ctx, link); !errors.Is(err, ErrLinkExists) {
+		t.Errorf("duplicate create: %v, want ErrLinkExists", err)
+	}
+	if sink.Creates != 1 || sink.Updates != 0 || sink.Deletes != 0 {
+		t.Errorf("counters %d/%d/%d, want 1/0/0", sink.Creates, sink.Updates, sink.Deletes)
+	}
+}
+
+func TestIndexMapperStableIDs(t *testing.T) {
+	sats, stations := testNodes()
+	nodes := []Node{sats[0], sats[1], stations[0], stations[1]}
+
+	forward, backward := NewIndexMapper("sat", "gs"), NewIndexMapper("sat", "gs")
+	for i := range nodes {
+		if _, err := forward.Identity(nodes[i]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := backward.Identity(nodes[len(nodes)-1-i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, node := range nodes {
+		a, _ := forward.Identity(node)
+		b, _ := backward.Identity(node)
+		if a != b {
+			t.Errorf("%T: identity %+v in one order, %+v in the other", node, a, b)
+		}
+	}
+
+	// 另一个运行中重新创建的同一颗卫星得到相同的 ID
+	again := NewSatellite(testTLEs[0][0], testTLEs[0][1])
+	first, _ := forward.Identity(sats[0])
+	forward.Release(sats[0])
+	second, err := forward.Identity(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("identity after release %+v, want %+v", second, first)
+	}
+	if len(forward.ids) != len(nodes) || len(forward.owners) != len(nodes) {
+		t.Errorf("mapper holds %d ids and %d owners, want %d", len(forward.ids), len(forward.owners), len(nodes))
+	}
+
+	if _, err := forward.Identity(nil); err == nil {
+		t.Error("identity of nil node: want error")
+	}
+}
+
+// collidingStations 找到 NodeKey 哈希到同一 ID 的两个地面站
+func collidingStations(t *testing.T) (*Station, *Station) {
+	t.Helper()
+	seen := make(map[int32]float64)
+	probe := NewStation(Position{})
+	for i := 0; i < 1<<20; i++ {
+		lat := float64(i) * 1e-6
+		probe.home.Latitude = lat
+		key, _ := NodeKey(probe)
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		n := int32(h.Sum32() & math.MaxInt32)
+		if other, ok := seen[n]; ok {
+			return NewStation(Position{Latitude: other}), NewStation(Position{Latitude: lat})
+		}
+		seen[n] = lat
+	}
+	t.Fatal("no colliding station keys found")
+	return nil, nil
+}
+
+func TestApplyDiffRemovedAfterRelease(t *testing.T) {
+	sats, _ := testNodes()
+	a, c := collidingStations(t)
+	mapper := NewIndexMapper("sat", "gs")
+	sink := NewFakeLinkSink()
+	r := NewLinkReconciler(mapper, sink)
+	ctx := context.Background()
+
+	link := activeLink(sats[0], a, 3*time.Millisecond)
+	if _, err := r.ApplyDiff(ctx, LinkDiff{Added: []LinkChange{{Kind: LinkAdded, Link: link}}}); err != nil {
+		t.Fatal(err)
+	}
+	idA, _ := mapper.Identity(a)
+
+	// a 从实例中移除后 ID 被释放，与它哈希冲突的 c 占用了同一个 ID
+	mapper.Release(a)
+	idC, err := mapper.Identity(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idC.ID != idA.ID {
+		t.Fatalf("colliding station got ID %d, want the released %d", idC.ID, idA.ID)
+	}
+	other := activeLink(sats[1], c, 5*time.Millisecond)
+	if _, err := r.ApplyDiff(ctx, LinkDiff{Added: []LinkChange{{Kind: LinkAdded, Link: other}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := r.ApplyDiff(ctx, LinkDiff{Removed: []LinkChange{{Kind: LinkRemoved, Link: link}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (ReconcileResult{Deleted: 1}) {
+		t.Errorf("result %+v, want one deletion", result)
+	}
+	// 删除的是 a 的链路，c 的链路保留，且没有为已释放的 a 重新分配 ID
+	left := sink.Links()
+	if len(left) != 1 || left[0].Dst != idC.ID || left[0].Src == left[0].Dst {
+		t.Errorf("sink links %+v, want only the link to station c", left)
+	}
+	if _, ok := mapper.ids[a]; ok {
+		t.Error("removing the link re-allocated an ID for the released station")
+	}
+	if len(mapper.owners) != 3 {
+		t.Errorf("mapper holds %d IDs, want 3", len(mapper.owners))
+	}
+
+	// 再次删除同一条链路时报告未找到，不会删掉同一 Uid 上别的链路
+	if _, err := r.ApplyDiff(ctx, LinkDiff{Removed: []LinkChange{{Kind: LinkRemoved, Link: link}}}); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("removing twice: %v, want ErrLinkNotFound", err)
+	}
+}
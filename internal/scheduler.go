@@ -1,70 +1,622 @@
 package demokubenet
 
 import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// ErrBusClosed 调度器已停止时发布的事件会返回该错误
+var ErrBusClosed = errors.New("event bus closed")
+
+type SubscriptionID uint64
+
+type subscription struct {
+	id      SubscriptionID
+	handler EventHandler
+	opts    HandlerOptions
+}
+
 type EventBus struct {
-	handlers   map[EventType][]EventHandler
-	eventQueue chan EventWrapper
+	// mu 保护 handlers，切片按写时复制更新，dispatch 拿到的快照不会被修改
+	mu         sync.RWMutex
+	handlers   map[EventType][]subscription
+	nextSubID  SubscriptionID
+	eventQueue *eventQueue
+
+	// 按顺序执行的事件类型：同一类型的事件整体串行，前一个事件的所有处理器结束后
+	// 下一个事件的处理器才开始。值为最近一次派发的事件的完成通道
+	seqMu      sync.Mutex
+	sequential map[EventType]chan struct{}
+
+	// 生命周期：closeMu 保护 running 以及每次 Start 创建的上下文和通道
+	closeMu  sync.RWMutex
+	running  bool
+	closing  atomic.Bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	abort    chan struct{}
+	done     chan struct{}
+	inflight sync.WaitGroup
+
+	errMu        sync.Mutex
+	shutdownErrs []error
+
+	// 错误回调和死信回调，未设置时只记录日志
+	hookMu     sync.RWMutex
+	onError    func(Event, error)
+	deadLetter func(Event, error)
+
+	clock       Clock
+	timerMu     sync.Mutex
+	timers      timerQueue
+	timerIndex  map[TimerID]*timerEntry
+	nextTimerID TimerID
+	timerSeq    uint64
+	wake        chan struct{}
 }
 
 type EventWrapper struct {
-	Event Event
-	Wg    *sync.WaitGroup
+	Event  Event
+	Wg     *sync.WaitGroup
+	Result *Future
+	// 按 PolicyCoalesce 被本事件替换的事件，与本事件一起结束
+	merged []EventWrapper
 }
 
+// NewEventBus 创建队列长度为 queueSize 的调度器，队列满时发布方阻塞
 func NewEventBus(queueSize int) *EventBus {
+	return NewEventBusWithPolicy(queueSize, PolicyBlock)
+}
+
+// NewEventBusWithPolicy 创建调度器并指定队列满时的处理策略
+func NewEventBusWithPolicy(queueSize int, policy QueuePolicy) *EventBus {
 	eb := &EventBus{
-		eventQueue: make(chan EventWrapper, queueSize),
-		handlers:   make(map[EventType][]EventHandler),
+		eventQueue: newEventQueue(queueSize, policy),
+		ctx:        context.Background(),
+		handlers:   make(map[EventType][]subscription),
+		sequential: make(map[EventType]chan struct{}),
+		clock:      NewRealClock(),
+		timerIndex: make(map[TimerID]*timerEntry),
+		wake:       make(chan struct{}, 1),
 	}
 	return eb
 }
 
-func (eb *EventBus) Subscribe(eventType string, h EventHandler) {
-	eb.handlers[eventType] = append(eb.handlers[eventType], h)
+// SetQueuePolicy 修改队列满时的处理策略，调度器运行中也可以调用
+func (eb *EventBus) SetQueuePolicy(policy QueuePolicy) {
+	eb.eventQueue.setPolicy(policy)
+}
+
+// Stats 返回事件队列的深度和计数
+func (eb *EventBus) Stats() QueueStats {
+	return eb.eventQueue.snapshot()
+}
+
+// SetSequential 设置 eventType 的事件是否整体串行执行。默认情况下排队的事件并发派发，
+// 只有同一事件内的处理器按 HandlerOptions 排序；多个处理器共同构成一轮流水线时
+// 需要串行，避免下一轮的步骤插入到本轮的步骤之间
+func (eb *EventBus) SetSequential(eventType EventType, on bool) {
+	eb.seqMu.Lock()
+	defer eb.seqMu.Unlock()
+	if !on {
+		delete(eb.sequential, eventType)
+		return
+	}
+	if _, ok := eb.sequential[eventType]; !ok {
+		done := make(chan struct{})
+		close(done)
+		eb.sequential[eventType] = done
+	}
+}
+
+// sequence 对串行的事件类型返回前一个事件的完成通道和本事件的完成通道，否则返回 nil
+func (eb *EventBus) sequence(eventType EventType) (prev <-chan struct{}, cur chan struct{}) {
+	eb.seqMu.Lock()
+	defer eb.seqMu.Unlock()
+	last, ok := eb.sequential[eventType]
+	if !ok {
+		return nil, nil
+	}
+	cur = make(chan struct{})
+	eb.sequential[eventType] = cur
+	return last, cur
+}
+
+// SetClock 设置定时事件使用的时钟，需在 Start 之前调用
+func (eb *EventBus) SetClock(c Clock) {
+	eb.clock = c
+}
+
+// Subscribe 注册处理器，返回的 SubscriptionID 用于 Unsubscribe，调度器运行中也可以调用
+func (eb *EventBus) Subscribe(eventType string, h EventHandler) SubscriptionID {
+	return eb.SubscribeWithOptions(eventType, h, HandlerOptions{})
+}
+
+// SubscribeWithOptions 注册带有阶段或依赖关系的处理器，见 HandlerOptions
+func (eb *EventBus) SubscribeWithOptions(eventType string, h EventHandler, opts HandlerOptions) SubscriptionID {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.nextSubID++
+	old := eb.handlers[eventType]
+	subs := make([]subscription, len(old), len(old)+1)
+	copy(subs, old)
+	eb.handlers[eventType] = append(subs, subscription{id: eb.nextSubID, handler: h, opts: opts})
+	return eb.nextSubID
+}
+
+// Unsubscribe 注销处理器，已经开始执行的处理器不受影响
+func (eb *EventBus) Unsubscribe(id SubscriptionID) bool {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	for eventType, old := range eb.handlers {
+		for i, sub := range old {
+			if sub.id != id {
+				continue
+			}
+			subs := make([]subscription, 0, len(old)-1)
+			subs = append(subs, old[:i]...)
+			subs = append(subs, old[i+1:]...)
+			if len(subs) == 0 {
+				delete(eb.handlers, eventType)
+			} else {
+				eb.handlers[eventType] = subs
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// HasSubscribers 事件类型当前是否有订阅者
+func (eb *EventBus) HasSubscribers(eventType EventType) bool {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	return len(eb.handlers[eventType]) > 0
+}
+
+// SetErrorHandler 设置处理器出错时的回调，每个出错的处理器调用一次
+func (eb *EventBus) SetErrorHandler(h func(Event, error)) {
+	eb.hookMu.Lock()
+	defer eb.hookMu.Unlock()
+	eb.onError = h
+}
+
+// SetDeadLetterHandler 设置死信回调：处理失败、无人订阅或因停止被拒绝的事件
+// 会连同原因（处理器错误的合并）一起交给它，便于记录或重新发布
+func (eb *EventBus) SetDeadLetterHandler(h func(Event, error)) {
+	eb.hookMu.Lock()
+	defer eb.hookMu.Unlock()
+	eb.deadLetter = h
+}
+
+// Publish 将事件放入队列并返回 Future，调用方可等待处理结果并获取全部处理器错误
+func (eb *EventBus) Publish(evt Event) *Future {
+	f := newFuture(evt)
+	eb.enqueue(EventWrapper{Event: evt, Result: f})
+	return f
+}
+
+// PublishWithWait 将事件放入队列，事件的所有处理器完成后调用一次 wg.Done()。
+// 调度器已停止时返回 ErrBusClosed，按队列策略被丢弃时返回 ErrEventDropped，
+// 这两种情况都会立即调用 wg.Done() 避免调用方永久等待
+func (eb *EventBus) PublishWithWait(evt Event, wg *sync.WaitGroup) error {
+	return eb.enqueue(EventWrapper{Event: evt, Wg: wg})
+}
+
+// TryPublish 与 Publish 相同，但队列已满时不等待，返回以 ErrEventDropped 结束的 Future。
+// 处理器中发布事件应使用它：fireTimer 同步等待处理器完成，处理器阻塞在满队列上会使调度协程死锁
+func (eb *EventBus) TryPublish(evt Event) *Future {
+	f := newFuture(evt)
+	eb.push(EventWrapper{Event: evt, Result: f}, false)
+	return f
+}
+
+func (eb *EventBus) enqueue(w EventWrapper) error {
+	return eb.push(w, true)
+}
+
+func (eb *EventBus) push(w EventWrapper, block bool) error {
+	dropped, err := eb.eventQueue.push(w, block)
+	if dropped != nil {
+		eb.finish(*dropped, ErrEventDropped)
+	}
+	if err != nil {
+		eb.finish(w, err)
+		return err
+	}
+	eb.notify()
+	return nil
+}
+
+// Context 返回调度器的上下文，Stop 超时后被取消，耗时的处理器应检查它并尽早返回
+func (eb *EventBus) Context() context.Context {
+	eb.closeMu.RLock()
+	defer eb.closeMu.RUnlock()
+	return eb.ctx
+}
+
+// ScheduleAt 在仿真时刻 at 触发一次 evt，evt.Time 会被设置为 at
+// wg 不为 nil 时在事件处理完成后调用一次 wg.Done()
+func (eb *EventBus) ScheduleAt(evt Event, at time.Time, wg *sync.WaitGroup) TimerID {
+	return eb.addTimer(&timerEntry{
+		at:    at,
+		event: evt,
+		wg:    wg,
+	})
+}
+
+// ScheduleEvery 从 start 开始每隔 interval 仿真时间触发一次 evt，共 count 次，
+// count <= 0 表示一直触发直到 Cancel。start 为零值时从时钟当前时刻开始。
+// 使用 SimulatedClock 时各轮之间没有等待，见 SimulatedClock。
+// wg 不为 nil 时每次处理完成后调用一次 wg.Done()。
+// interval 不为正时只能触发一次，count 不为 1 时返回错误，不会安排任何事件
+func (eb *EventBus) ScheduleEvery(evt Event, start time.Time, interval time.Duration, count int, wg *sync.WaitGroup) (TimerID, error) {
+	if interval <= 0 && count != 1 {
+		return 0, fmt.Errorf("schedule every %v for %d rounds: interval must be positive", interval, count)
+	}
+	if start.IsZero() {
+		start = eb.clock.Now()
+	}
+	return eb.addTimer(&timerEntry{
+		at:        start,
+		interval:  interval,
+		remaining: count,
+		event:     evt,
+		wg:        wg,
+	}), nil
+}
+
+// Cancel 取消尚未触发的定时事件或周期事件
+func (eb *EventBus) Cancel(id TimerID) bool {
+	eb.timerMu.Lock()
+	defer eb.timerMu.Unlock()
+	entry, ok := eb.timerIndex[id]
+	if !ok {
+		return false
+	}
+	heap.Remove(&eb.timers, entry.index)
+	delete(eb.timerIndex, id)
+	eb.notify()
+	return true
+}
+
+func (eb *EventBus) addTimer(entry *timerEntry) TimerID {
+	eb.timerMu.Lock()
+	defer eb.timerMu.Unlock()
+	eb.nextTimerID++
+	eb.timerSeq++
+	entry.id = eb.nextTimerID
+	entry.seq = eb.timerSeq
+	heap.Push(&eb.timers, entry)
+	eb.timerIndex[entry.id] = entry
+	eb.notify()
+	return entry.id
+}
+
+// notify 唤醒调度协程重新计算下一个定时事件
+func (eb *EventBus) notify() {
+	select {
+	case eb.wake <- struct{}{}:
+	default:
+	}
+}
+
+// nextTimer 返回距离最早的定时事件还需等待的真实时长
+func (eb *EventBus) nextTimer() (time.Duration, bool) {
+	eb.timerMu.Lock()
+	defer eb.timerMu.Unlock()
+	if len(eb.timers) == 0 {
+		return 0, false
+	}
+	return eb.clock.Until(eb.timers[0].at), true
 }
 
-// func (eb *EventBus) Publish(evt Event) {
-// 	eb.eventQueue <- evt
-// }
+// popDueTimer 取出最早的定时事件，周期事件会按 interval 重新入队
+func (eb *EventBus) popDueTimer() (*timerEntry, bool) {
+	eb.timerMu.Lock()
+	defer eb.timerMu.Unlock()
+	if len(eb.timers) == 0 || eb.clock.Until(eb.timers[0].at) > 0 {
+		return nil, false
+	}
+	entry := heap.Pop(&eb.timers).(*timerEntry)
+	delete(eb.timerIndex, entry.id)
 
-func (eb *EventBus) PublishWithWait(evt Event, wg *sync.WaitGroup) {
-	eb.eventQueue <- EventWrapper{
-		Event: evt,
-		Wg:    wg,
+	fired := *entry
+	if entry.interval > 0 && entry.remaining != 1 {
+		if entry.remaining > 0 {
+			entry.remaining--
+		}
+		eb.timerSeq++
+		entry.at = entry.at.Add(entry.interval)
+		entry.seq = eb.timerSeq
+		heap.Push(&eb.timers, entry)
+		eb.timerIndex[entry.id] = entry
 	}
+	return &fired, true
 }
 
+// Start 启动调度协程，Stop 返回后可以再次 Start
 func (eb *EventBus) Start() {
 	// log.Println("scheduler.Start")
+	eb.closeMu.Lock()
+	defer eb.closeMu.Unlock()
+	if eb.running {
+		log.Println("scheduler already running")
+		return
+	}
+	eb.eventQueue.reopen()
+	eb.closing.Store(false)
+	eb.ctx, eb.cancel = context.WithCancel(context.Background())
+	eb.abort = make(chan struct{})
+	eb.done = make(chan struct{})
+	eb.running = true
+	go eb.run(eb.abort, eb.done)
+}
+
+// Stop 停止接收新事件，处理完队列中已有的事件并等待执行中的处理器结束。
+// ctx 结束时剩余的排队事件被拒绝、调度器上下文被取消，并返回 ctx.Err()。
+// 停止过程中处理器返回的错误会一并返回
+func (eb *EventBus) Stop(ctx context.Context) error {
+	eb.closeMu.Lock()
+	if !eb.running || !eb.eventQueue.close() {
+		eb.closeMu.Unlock()
+		return nil
+	}
+	eb.closing.Store(true)
+	eb.notify()
+	abort, done, cancel := eb.abort, eb.done, eb.cancel
+	eb.closeMu.Unlock()
+
+	var errs []error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		close(abort)
+		cancel()
+		errs = append(errs, ctx.Err())
+	}
+
+	// 等待仍在执行的处理器
+	handlersDone := make(chan struct{})
 	go func() {
-		// log.Println("scheduler goroutine running")
-		for evt := range eb.eventQueue {
-			eb.dispatch(evt)
+		eb.inflight.Wait()
+		close(handlersDone)
+	}()
+	select {
+	case <-handlersDone:
+	case <-ctx.Done():
+		cancel()
+		if len(errs) == 0 {
+			errs = append(errs, ctx.Err())
 		}
+	}
+	cancel()
+
+	eb.errMu.Lock()
+	errs = append(errs, eb.shutdownErrs...)
+	eb.shutdownErrs = nil
+	eb.errMu.Unlock()
+	return errors.Join(errs...)
+}
+
+// Close 等价于不设超时的 Stop
+func (eb *EventBus) Close() error {
+	return eb.Stop(context.Background())
+}
+
+func (eb *EventBus) run(abort, done chan struct{}) {
+	// log.Println("scheduler goroutine running")
+	defer func() {
+		eb.clearTimers()
+		eb.closeMu.Lock()
+		eb.running = false
+		eb.closeMu.Unlock()
+		close(done)
 	}()
+	for {
+		if eb.closing.Load() {
+			eb.drain(abort)
+			return
+		}
+
+		// 先处理已经入队的即时事件，避免仿真时钟下定时事件饿死它们
+		if evt, ok := eb.eventQueue.pop(); ok {
+			eb.dispatch(evt)
+			continue
+		}
+
+		wait, ok := eb.nextTimer()
+		if ok && wait <= 0 {
+			eb.fireTimer()
+			continue
+		}
+
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if ok {
+			timer = time.NewTimer(wait)
+			timerC = timer.C
+		}
+		select {
+		case <-timerC:
+		case <-eb.wake:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// drain 停止时处理队列中剩余的事件，abort 关闭后其余事件直接拒绝
+func (eb *EventBus) drain(abort chan struct{}) {
+	rejected := 0
+	for {
+		evt, ok := eb.eventQueue.pop()
+		if !ok {
+			break
+		}
+		select {
+		case <-abort:
+			rejected++
+			eb.finish(evt, ErrBusClosed)
+		default:
+			eb.dispatch(evt)
+		}
+	}
+	if rejected > 0 {
+		eb.recordShutdownErr(fmt.Errorf("%d queued events rejected: %w", rejected, ErrBusClosed))
+	}
 }
 
-func (eb *EventBus) dispatch(evtWrapper EventWrapper) {
+// clearTimers 丢弃尚未触发的定时事件，并释放它们剩余的 wg 计数
+func (eb *EventBus) clearTimers() {
+	eb.timerMu.Lock()
+	defer eb.timerMu.Unlock()
+	for _, entry := range eb.timers {
+		if entry.wg == nil {
+			continue
+		}
+		left := 0
+		if entry.interval == 0 {
+			left = 1
+		} else if entry.remaining > 0 {
+			left = entry.remaining
+		}
+		for i := 0; i < left; i++ {
+			entry.wg.Done()
+		}
+	}
+	eb.timers = nil
+	eb.timerIndex = make(map[TimerID]*timerEntry)
+}
+
+func (eb *EventBus) recordShutdownErr(err error) {
+	eb.errMu.Lock()
+	defer eb.errMu.Unlock()
+	eb.shutdownErrs = append(eb.shutdownErrs, err)
+}
+
+// fireTimer 推进时钟到事件时刻并同步处理，保证仿真时间单调前进
+func (eb *EventBus) fireTimer() {
+	entry, ok := eb.popDueTimer()
+	if !ok {
+		return
+	}
+	eb.clock.AdvanceTo(entry.at)
+	evt := entry.event
+	evt.Time = entry.at
+	eb.dispatch(EventWrapper{Event: evt, Wg: entry.wg}).Wait()
+}
+
+// dispatch 按阶段和依赖关系并发执行事件的所有处理器，每个处理器在其前置处理器
+// 完成后立即开始。全部完成后调用一次 Wg.Done() 并完成 Result
+func (eb *EventBus) dispatch(evtWrapper EventWrapper) *sync.WaitGroup {
 	// log.Printf("Scheduler: Dispatching event")
-	// eb.mu.RLock()
 	evt := evtWrapper.Event
-	wg := evtWrapper.Wg
 
+	eb.mu.RLock()
 	handlers := eb.handlers[evt.Type]
-	// eb.mu.RUnlock()
+	eb.mu.RUnlock()
+
+	var done sync.WaitGroup
+	if len(handlers) == 0 {
+		eb.finish(evtWrapper, fmt.Errorf("event %s: %w", evt.Type, ErrNoHandlers))
+		return &done
+	}
+
+	deps, err := buildHandlerGraph(handlers)
+	if err != nil {
+		eb.finish(evtWrapper, fmt.Errorf("event %s: %w", evt.Type, err))
+		return &done
+	}
 
-	for _, h := range handlers {
+	// 串行的事件类型等待前一个事件完成，本事件结束（含失败）后放行下一个
+	prev, cur := eb.sequence(evt.Type)
 
-		go func(handler EventHandler) {
-			defer wg.Done()
-			if err := handler(eb, evt); err != nil {
-				// 错误处理（可扩展错误回调）
-				log.Printf("Error handling event: %v", err)
+	var errMu sync.Mutex
+	var errs []error
+	finished := make([]chan struct{}, len(handlers))
+	failed := make([]bool, len(handlers))
+	for i := range handlers {
+		finished[i] = make(chan struct{})
+	}
+	for i, sub := range handlers {
+		done.Add(1)
+		eb.inflight.Add(1)
+		go func(i int, sub subscription) {
+			defer eb.inflight.Done()
+			defer done.Done()
+			defer close(finished[i])
+			if prev != nil {
+				<-prev
+			}
+			for _, j := range deps[i] {
+				<-finished[j]
+				if failed[j] {
+					failed[i] = true
+					errMu.Lock()
+					errs = append(errs, fmt.Errorf("handler %s skipped: %s failed", handlerName(sub), handlerName(handlers[j])))
+					errMu.Unlock()
+					return
+				}
+			}
+			if err := sub.handler(eb, evt); err != nil {
+				failed[i] = true
+				eb.handleError(evt, err)
+				errMu.Lock()
+				errs = append(errs, err)
+				errMu.Unlock()
 			}
-		}(h)
+		}(i, sub)
+	}
+	eb.inflight.Add(1)
+	go func() {
+		defer eb.inflight.Done()
+		done.Wait()
+		if cur != nil {
+			close(cur)
+		}
+		eb.finish(evtWrapper, errors.Join(errs...))
+	}()
+	return &done
+}
+
+func (eb *EventBus) handleError(evt Event, err error) {
+	eb.hookMu.RLock()
+	onError := eb.onError
+	eb.hookMu.RUnlock()
+	if onError != nil {
+		onError(evt, err)
+	} else {
+		log.Printf("Error handling event %s: %v", evt.Type, err)
+	}
+	if eb.closing.Load() {
+		eb.recordShutdownErr(err)
+	}
+}
+
+// finish 结束一次事件投递：失败时交给死信回调，然后通知等待方
+func (eb *EventBus) finish(evtWrapper EventWrapper, err error) {
+	if err != nil {
+		eb.hookMu.RLock()
+		deadLetter := eb.deadLetter
+		eb.hookMu.RUnlock()
+		if deadLetter != nil {
+			deadLetter(evtWrapper.Event, err)
+		}
+	}
+	for _, w := range append(evtWrapper.merged, evtWrapper) {
+		if w.Result != nil {
+			w.Result.complete(err)
+		}
+		if w.Wg != nil {
+			w.Wg.Done()
+		}
 	}
 }
@@ -0,0 +1,206 @@
+package demokubenet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startBus 启动调度器，测试结束时关闭
+func startBus(t *testing.T, eb *EventBus) *EventBus {
+	t.Helper()
+	eb.Start()
+	t.Cleanup(func() { eb.Close() })
+	return eb
+}
+
+// waitTimeout 等待 wg，超时视为死锁
+func waitTimeout(t *testing.T, wg *sync.WaitGroup, d time.Duration) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatal("timed out waiting for events")
+	}
+}
+
+// recorder 记录处理器收到的事件，可以并发调用
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) handle(eb *EventBus, evt Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, evt)
+	return nil
+}
+
+func (r *recorder) snapshot() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// waitFuture 等待 Future 完成并返回它的错误，超时视为死锁
+func waitFuture(t *testing.T, f *Future) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := f.WaitContext(ctx)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		t.Fatalf("event %s did not complete", f.Event.Type)
+	}
+	return err
+}
+
+func TestScheduleEveryRejectsNonPositiveInterval(t *testing.T) {
+	eb := NewEventBus(10)
+	eb.SetClock(NewSimulatedClock(clockStart, time.Second))
+	var rec recorder
+	eb.Subscribe("tick", rec.handle)
+	startBus(t, eb)
+
+	for _, c := range []struct {
+		interval time.Duration
+		count    int
+	}{{0, 3}, {0, 0}, {-time.Second, 2}} {
+		if _, err := eb.ScheduleEvery(Event{Type: "tick"}, clockStart, c.interval, c.count, nil); err == nil {
+			t.Errorf("interval %v count %d: want error", c.interval, c.count)
+		}
+	}
+
+	// 只触发一次时 interval 没有意义，允许为 0
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if _, err := eb.ScheduleEvery(Event{Type: "tick"}, clockStart, 0, 1, &wg); err != nil {
+		t.Fatal(err)
+	}
+	waitTimeout(t, &wg, 5*time.Second)
+	if got := len(rec.snapshot()); got != 1 {
+		t.Errorf("%d ticks, want 1", got)
+	}
+}
+
+func TestStopDrainsQueuedEvents(t *testing.T) {
+	eb := NewEventBus(20)
+	release := make(chan struct{})
+	var rec recorder
+	eb.Subscribe("work", func(eb *EventBus, evt Event) error {
+		<-release
+		return rec.handle(eb, evt)
+	})
+	eb.Start()
+
+	var futures []*Future
+	for i := 0; i < 10; i++ {
+		futures = append(futures, eb.Publish(Event{Type: "work", Payload: i}))
+	}
+	stopped := make(chan error)
+	go func() { stopped <- eb.Close() }()
+	close(release)
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+	for i, f := range futures {
+		if err := waitFuture(t, f); err != nil {
+			t.Errorf("event %d: %v", i, err)
+		}
+	}
+	if got := len(rec.snapshot()); got != 10 {
+		t.Errorf("%d events handled before Close returned, want 10", got)
+	}
+
+	// 停止后发布的事件立即以 ErrBusClosed 结束
+	if err := waitFuture(t, eb.Publish(Event{Type: "work"})); !errors.Is(err, ErrBusClosed) {
+		t.Errorf("Publish after Close: %v, want ErrBusClosed", err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if err := eb.PublishWithWait(Event{Type: "work"}, &wg); !errors.Is(err, ErrBusClosed) {
+		t.Errorf("PublishWithWait after Close: %v, want ErrBusClosed", err)
+	}
+	waitTimeout(t, &wg, time.Second)
+}
+
+func TestStopTimeoutRejectsQueuedEvents(t *testing.T) {
+	eb := NewEventBus(20)
+	eb.SetClock(NewSimulatedClock(clockStart, time.Second))
+	// 定时事件同步执行，处理器阻塞时调度协程无法取出排队的事件
+	started := make(chan struct{})
+	eb.Subscribe("slow", func(eb *EventBus, evt Event) error {
+		close(started)
+		<-eb.Context().Done()
+		return eb.Context().Err()
+	})
+	eb.Subscribe("work", func(*EventBus, Event) error { return nil })
+	eb.Start()
+
+	eb.ScheduleAt(Event{Type: "slow"}, clockStart, nil)
+	<-started
+	var queued []*Future
+	for i := 0; i < 3; i++ {
+		queued = append(queued, eb.Publish(Event{Type: "work"}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := eb.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() = %v, want DeadlineExceeded", err)
+	}
+	for i, f := range queued {
+		if err := waitFuture(t, f); !errors.Is(err, ErrBusClosed) {
+			t.Errorf("queued event %d: %v, want ErrBusClosed", i, err)
+		}
+	}
+}
+
+func TestStopReleasesPendingTimers(t *testing.T) {
+	eb := NewEventBus(10)
+	eb.Subscribe("later", func(*EventBus, Event) error { return nil })
+	eb.Start()
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	eb.ScheduleAt(Event{Type: "later"}, time.Now().Add(time.Hour), &wg)
+	if _, err := eb.ScheduleEvery(Event{Type: "later"}, time.Now().Add(time.Hour), time.Minute, 3, &wg); err != nil {
+		t.Fatal(err)
+	}
+	if err := eb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 未触发的定时事件在停止时释放剩余的 wg 计数
+	waitTimeout(t, &wg, time.Second)
+}
+
+func TestRestartAfterStop(t *testing.T) {
+	eb := NewEventBus(10)
+	eb.Subscribe("ping", func(*EventBus, Event) error { return nil })
+	for i := 0; i < 3; i++ {
+		eb.Start()
+		if err := waitFuture(t, eb.Publish(Event{Type: "ping"})); err != nil {
+			t.Fatalf("round %d: %v", i, err)
+		}
+		if err := eb.Close(); err != nil {
+			t.Fatalf("round %d: Close() = %v", i, err)
+		}
+	}
+	// 重复 Close 不报错
+	if err := eb.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
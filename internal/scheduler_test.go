@@ -0,0 +1,202 @@
+package demokubenet
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduleAtOrder(t *testing.T) {
+	clock := NewSimulatedClock(clockStart, time.Second)
+	eb := NewEventBus(10)
+	eb.SetClock(clock)
+	var rec recorder
+	eb.Subscribe("timer", rec.handle)
+
+	// 按时刻触发，同一时刻按提交顺序
+	var wg sync.WaitGroup
+	wg.Add(4)
+	eb.ScheduleAt(Event{Type: "timer", Payload: "c"}, clockStart.Add(3*time.Minute), &wg)
+	eb.ScheduleAt(Event{Type: "timer", Payload: "a"}, clockStart.Add(time.Minute), &wg)
+	eb.ScheduleAt(Event{Type: "timer", Payload: "b1"}, clockStart.Add(2*time.Minute), &wg)
+	eb.ScheduleAt(Event{Type: "timer", Payload: "b2"}, clockStart.Add(2*time.Minute), &wg)
+	startBus(t, eb)
+	waitTimeout(t, &wg, 5*time.Second)
+
+	want := []struct {
+		payload string
+		at      time.Duration
+	}{{"a", time.Minute}, {"b1", 2 * time.Minute}, {"b2", 2 * time.Minute}, {"c", 3 * time.Minute}}
+	got := rec.snapshot()
+	if len(got) != len(want) {
+		t.Fatalf("%d events fired, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Payload != w.payload || !got[i].Time.Equal(clockStart.Add(w.at)) {
+			t.Errorf("event %d: %v at %v, want %s at %v", i, got[i].Payload, got[i].Time, w.payload, clockStart.Add(w.at))
+		}
+	}
+	// 仿真时钟推进到最后一个事件的时刻
+	if now := clock.Now(); !now.Equal(clockStart.Add(3 * time.Minute)) {
+		t.Errorf("clock at %v after timers", now)
+	}
+}
+
+func TestScheduleEvery(t *testing.T) {
+	eb := NewEventBus(10)
+	eb.SetClock(NewSimulatedClock(clockStart, time.Second))
+	var rec recorder
+	eb.Subscribe("tick", rec.handle)
+	startBus(t, eb)
+
+	var wg sync.WaitGroup
+	wg.Add(5)
+	if _, err := eb.ScheduleEvery(Event{Type: "tick"}, clockStart, 30*time.Second, 5, &wg); err != nil {
+		t.Fatal(err)
+	}
+	waitTimeout(t, &wg, 5*time.Second)
+
+	got := rec.snapshot()
+	if len(got) != 5 {
+		t.Fatalf("%d ticks, want 5", len(got))
+	}
+	for i, evt := range got {
+		if want := clockStart.Add(time.Duration(i) * 30 * time.Second); !evt.Time.Equal(want) {
+			t.Errorf("tick %d at %v, want %v", i, evt.Time, want)
+		}
+	}
+}
+
+func TestScheduleEveryDoesNotStarveQueue(t *testing.T) {
+	// 仿真时钟下不限次数的周期事件不会饿死即时事件
+	eb := NewEventBus(10)
+	eb.SetClock(NewSimulatedClock(clockStart, time.Second))
+	eb.Subscribe("tick", func(*EventBus, Event) error { return nil })
+	eb.Subscribe("now", func(*EventBus, Event) error { return nil })
+	startBus(t, eb)
+
+	id, err := eb.ScheduleEvery(Event{Type: "tick"}, time.Time{}, time.Second, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := eb.Publish(Event{Type: "now"})
+	select {
+	case <-f.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("published event starved by periodic timer")
+	}
+	if !eb.Cancel(id) {
+		t.Error("Cancel of running periodic timer returned false")
+	}
+}
+
+func TestCancelTimer(t *testing.T) {
+	eb := NewEventBus(10)
+	var rec recorder
+	eb.Subscribe("timer", rec.handle)
+	startBus(t, eb)
+
+	id := eb.ScheduleAt(Event{Type: "timer"}, time.Now().Add(time.Hour), nil)
+	if !eb.Cancel(id) {
+		t.Fatal("Cancel returned false for a pending timer")
+	}
+	if eb.Cancel(id) {
+		t.Error("second Cancel returned true")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	eb.ScheduleAt(Event{Type: "timer", Payload: "soon"}, time.Now().Add(10*time.Millisecond), &wg)
+	waitTimeout(t, &wg, 5*time.Second)
+	if got := rec.snapshot(); len(got) != 1 || got[0].Payload != "soon" {
+		t.Errorf("fired %v, want only the uncancelled timer", got)
+	}
+}
+
+func TestAcceleratedClock(t *testing.T) {
+	for _, factor := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		if _, err := NewAcceleratedClock(clockStart, factor); err == nil {
+			t.Errorf("factor %v: want error", factor)
+		}
+	}
+	c, err := NewAcceleratedClock(clockStart, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 仿真时间的 1 小时对应真实时间的 1 分钟
+	if d := c.Until(clockStart.Add(time.Hour)); d <= 59*time.Second || d > time.Minute {
+		t.Errorf("Until(start+1h) = %v, want about 1m", d)
+	}
+	if now := c.Now(); now.Before(clockStart) || now.After(clockStart.Add(time.Minute)) {
+		t.Errorf("Now() = %v", now)
+	}
+}
+
+func TestUnsubscribeDuringDispatch(t *testing.T) {
+	eb := NewEventBus(10)
+	var rec recorder
+	var selfID SubscriptionID
+	selfID = eb.Subscribe("tick", func(eb *EventBus, evt Event) error {
+		if !eb.Unsubscribe(selfID) {
+			t.Error("Unsubscribe from inside the handler failed")
+		}
+		return rec.handle(eb, Event{Type: "once"})
+	})
+	eb.Subscribe("tick", rec.handle)
+	startBus(t, eb)
+
+	// 本次事件已取得处理器快照，两个处理器都会执行；之后只剩第二个
+	for i := 0; i < 3; i++ {
+		if err := waitFuture(t, eb.Publish(Event{Type: "tick"})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var once, ticks int
+	for _, evt := range rec.snapshot() {
+		if evt.Type == "once" {
+			once++
+		} else {
+			ticks++
+		}
+	}
+	if once != 1 || ticks != 3 {
+		t.Errorf("once = %d, ticks = %d, want 1 and 3", once, ticks)
+	}
+	if eb.Unsubscribe(selfID) {
+		t.Error("second Unsubscribe returned true")
+	}
+}
+
+func TestConcurrentSubscribe(t *testing.T) {
+	eb := NewEventBus(100)
+	eb.Subscribe("tick", func(*EventBus, Event) error { return nil })
+	startBus(t, eb)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				id := eb.Subscribe("tick", func(*EventBus, Event) error { return nil })
+				eb.HasSubscribers("tick")
+				if !eb.Unsubscribe(id) {
+					t.Error("Unsubscribe of a live subscription failed")
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if err := eb.Publish(Event{Type: "tick"}).Wait(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	waitTimeout(t, &wg, 10*time.Second)
+	if !eb.HasSubscribers("tick") {
+		t.Error("the original subscriber is gone")
+	}
+}
@@ -0,0 +1,54 @@
+package demokubenet
+
+import (
+	"sync"
+	"time"
+)
+
+type TimerID uint64
+
+// timerEntry 定时事件，interval 非零时为周期事件
+type timerEntry struct {
+	id        TimerID
+	seq       uint64 // 同一时刻按提交顺序触发
+	at        time.Time
+	interval  time.Duration
+	remaining int // 周期事件剩余次数，<= 0 表示不限次数
+	event     Event
+	wg        *sync.WaitGroup
+	index     int
+}
+
+// timerQueue 按触发时刻排序的小顶堆，实现 container/heap.Interface
+type timerQueue []*timerEntry
+
+func (q timerQueue) Len() int { return len(q) }
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x any) {
+	entry := x.(*timerEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *timerQueue) Pop() any {
+	old := *q
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*q = old[:n-1]
+	return entry
+}
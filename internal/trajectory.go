@@ -0,0 +1,262 @@
+package demokubenet
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 计算大圆航线使用的地球平均半径 (m)
+const meanEarthRadius = 6371008.8
+
+// TerminalType 地面终端的类型，移动终端的位置按 Trajectory 随时间变化
+type TerminalType int
+
+const (
+	TerminalFixed TerminalType = iota
+	TerminalShip
+	TerminalAircraft
+	TerminalVehicle
+)
+
+var terminalTypeNames = map[TerminalType]string{
+	TerminalFixed:    "fixed",
+	TerminalShip:     "ship",
+	TerminalAircraft: "aircraft",
+	TerminalVehicle:  "vehicle",
+}
+
+func (t TerminalType) String() string {
+	if name, ok := terminalTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TerminalType(%d)", int(t))
+}
+
+func ParseTerminalType(s string) (TerminalType, error) {
+	for t, name := range terminalTypeNames {
+		if strings.EqualFold(s, name) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown terminal type %q", s)
+}
+
+// Waypoint 航路点，Speed 为从该点驶向下一个航路点的速度 (m/s)
+type Waypoint struct {
+	Position Position
+	Speed    float64
+}
+
+// Trajectory 由航路点组成的轨迹，相邻航路点之间沿大圆航线匀速运动，高度线性变化。
+// Start 之前停在第一个航路点，走完之后停在最后一个航路点；Loop 为 true 时从最后一个航路点
+// 以它的速度返回第一个航路点并循环
+type Trajectory struct {
+	Start     time.Time
+	Waypoints []Waypoint
+	Loop      bool
+
+	legs []trajectoryLeg
+}
+
+// trajectoryLeg 一段航线，end 为到达终点时相对 Start 的时间 (s)
+type trajectoryLeg struct {
+	from, to Position
+	angle    float64 // 两点之间的地心角 (rad)
+	duration float64
+	end      float64
+}
+
+func NewTrajectory(start time.Time, waypoints []Waypoint, loop bool) (*Trajectory, error) {
+	if len(waypoints) == 0 {
+		return nil, fmt.Errorf("trajectory without waypoints")
+	}
+	t := &Trajectory{Start: start, Waypoints: waypoints, Loop: loop}
+	n := len(waypoints) - 1
+	if loop {
+		n++
+	}
+	end := 0.0
+	for i := 0; i < n; i++ {
+		from, to := waypoints[i], waypoints[(i+1)%len(waypoints)]
+		leg := trajectoryLeg{from: from.Position, to: to.Position}
+		leg.angle = centralAngle(from.Position, to.Position)
+		length := math.Hypot(leg.angle*meanEarthRadius, to.Position.Altitude-from.Position.Altitude)
+		if length > 0 {
+			if from.Speed <= 0 {
+				return nil, fmt.Errorf("waypoint %d: speed must be positive", i)
+			}
+			leg.duration = length / from.Speed
+		}
+		end += leg.duration
+		leg.end = end
+		t.legs = append(t.legs, leg)
+	}
+	return t, nil
+}
+
+// Duration 走完所有航线（Loop 时为一圈）需要的时间
+func (t *Trajectory) Duration() time.Duration {
+	if len(t.legs) == 0 {
+		return 0
+	}
+	return time.Duration(t.legs[len(t.legs)-1].end * float64(time.Second))
+}
+
+// At 返回 timestamp 时刻的位置和 ECEF 速度 (m/s)
+func (t *Trajectory) At(timestamp time.Time) (Position, Velocity) {
+	elapsed := timestamp.Sub(t.Start).Seconds()
+	total := 0.0
+	if len(t.legs) > 0 {
+		total = t.legs[len(t.legs)-1].end
+	}
+	switch {
+	case elapsed < 0 || total == 0:
+		return t.Waypoints[0].Position, Velocity{}
+	case t.Loop:
+		elapsed = math.Mod(elapsed, total)
+	case elapsed >= total:
+		return t.Waypoints[len(t.Waypoints)-1].Position, Velocity{}
+	}
+
+	i := sort.Search(len(t.legs), func(i int) bool { return t.legs[i].end > elapsed })
+	leg := t.legs[i]
+	s := elapsed - (leg.end - leg.duration)
+
+	// 速度取同一段航线上前后 0.5 s 位置的差分
+	const dt = 0.5
+	s0, s1 := math.Max(s-dt, 0), math.Min(s+dt, leg.duration)
+	p0, p1 := LLAtoECEF(leg.at(s0)), LLAtoECEF(leg.at(s1))
+	var v Velocity
+	if s1 > s0 {
+		v = Velocity{X: (p1[0] - p0[0]) / (s1 - s0), Y: (p1[1] - p0[1]) / (s1 - s0), Z: (p1[2] - p0[2]) / (s1 - s0)}
+	}
+	return leg.at(s), v
+}
+
+// at 返回出发 s 秒后的位置，水平方向按地心角插值（球面线性插值）
+func (l trajectoryLeg) at(s float64) Position {
+	f := 0.0
+	if l.duration > 0 {
+		f = s / l.duration
+	}
+	pos := Position{Altitude: l.from.Altitude + (l.to.Altitude-l.from.Altitude)*f}
+	// 航路点处直接取航路点的经纬度，避免插值的舍入误差
+	switch {
+	case l.angle == 0 || f == 0:
+		pos.Latitude, pos.Longitude = l.from.Latitude, l.from.Longitude
+		return pos
+	case f == 1:
+		pos.Latitude, pos.Longitude = l.to.Latitude, l.to.Longitude
+		return pos
+	}
+	a, b := unitVector(l.from), unitVector(l.to)
+	wa := math.Sin((1-f)*l.angle) / math.Sin(l.angle)
+	wb := math.Sin(f*l.angle) / math.Sin(l.angle)
+	x, y, z := wa*a[0]+wb*b[0], wa*a[1]+wb*b[1], wa*a[2]+wb*b[2]
+	pos.Latitude = math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi
+	pos.Longitude = math.Atan2(y, x) * 180 / math.Pi
+	return pos
+}
+
+// unitVector 球面上经纬度对应的单位向量
+func unitVector(pos Position) [3]float64 {
+	lat, lon := pos.Latitude*math.Pi/180, pos.Longitude*math.Pi/180
+	return [3]float64{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+// centralAngle 两点之间的地心角 (rad)
+func centralAngle(a, b Position) float64 {
+	u, v := unitVector(a), unitVector(b)
+	c := [3]float64{u[1]*v[2] - u[2]*v[1], u[2]*v[0] - u[0]*v[2], u[0]*v[1] - u[1]*v[0]}
+	return math.Atan2(math.Sqrt(dot(c, c)), dot(u, v))
+}
+
+// NewMobileStation 创建沿 traj 运动的移动终端，初始位置为 traj 起点
+func NewMobileStation(typ TerminalType, traj *Trajectory) *Station {
+	st := NewStation(traj.Waypoints[0].Position)
+	st.Type = typ
+	st.Trajectory = traj
+	return st
+}
+
+// ReadMobileStations 读取移动终端的轨迹文件，所有轨迹从 start 时刻出发。
+// 文件中 # 开头的行为注释，每个终端以 "terminal 类型 [loop]" 开始，类型为 ship、aircraft 或 vehicle，
+// 之后每行一个航路点：纬度 经度 海拔 (m) 速度 (m/s)，速度为驶向下一个航路点的速度
+func ReadMobileStations(filename string, start time.Time) ([]*Station, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var stations []*Station
+	var typ TerminalType
+	var loop bool
+	var waypoints []Waypoint
+	flush := func() error {
+		if waypoints == nil {
+			return nil
+		}
+		traj, err := NewTrajectory(start, waypoints, loop)
+		if err != nil {
+			return fmt.Errorf("%s: terminal %d: %w", filename, len(stations)+1, err)
+		}
+		stations = append(stations, NewMobileStation(typ, traj))
+		waypoints = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	seen := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] == "terminal" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%s: terminal 行缺少类型: %s", filename, line)
+			}
+			if typ, err = ParseTerminalType(fields[1]); err != nil {
+				return nil, fmt.Errorf("%s: %w", filename, err)
+			}
+			loop = len(fields) >= 3 && fields[2] == "loop"
+			waypoints = []Waypoint{}
+			seen = true
+			continue
+		}
+		if !seen {
+			return nil, fmt.Errorf("%s: 航路点之前缺少 terminal 行: %s", filename, line)
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("%s: 航路点格式为 纬度 经度 海拔 速度: %s", filename, line)
+		}
+		var v [4]float64
+		for i := range v {
+			if v[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+				return nil, fmt.Errorf("%s: 航路点格式错误: %s", filename, line)
+			}
+		}
+		waypoints = append(waypoints, Waypoint{
+			Position: Position{Latitude: v[0], Longitude: v[1], Altitude: v[2]},
+			Speed:    v[3],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return stations, nil
+}
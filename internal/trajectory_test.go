@@ -0,0 +1,221 @@
+package demokubenet
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var trajectoryStart = time.Date(2025, 7, 8, 0, 0, 0, 0, time.UTC)
+
+// equatorTrajectory 沿赤道向东 0 -> 1 -> 2 度经度，两段速度分别为 100 和 200 m/s
+func equatorTrajectory(t *testing.T, loop bool) *Trajectory {
+	t.Helper()
+	traj, err := NewTrajectory(trajectoryStart, []Waypoint{
+		{Position: Position{Longitude: 0}, Speed: 100},
+		{Position: Position{Longitude: 1, Altitude: 1000}, Speed: 200},
+		{Position: Position{Longitude: 2}, Speed: 200},
+	}, loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return traj
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func speed(v Velocity) float64 {
+	return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
+}
+
+func samePosition(a, b Position) bool {
+	return math.Abs(a.Latitude-b.Latitude) < 1e-9 && math.Abs(a.Longitude-b.Longitude) < 1e-9 && math.Abs(a.Altitude-b.Altitude) < 1e-6
+}
+
+func TestTrajectoryAt(t *testing.T) {
+	traj := equatorTrajectory(t, false)
+	leg := math.Hypot(math.Pi/180*meanEarthRadius, 1000)
+	leg1, leg2 := leg/100, leg/200
+
+	if got, want := traj.Duration().Seconds(), leg1+leg2; math.Abs(got-want) > 1e-6 {
+		t.Fatalf("duration %g s, want %g s", got, want)
+	}
+
+	t.Run("leg boundaries", func(t *testing.T) {
+		for _, c := range []struct {
+			name    string
+			elapsed float64
+			want    Position
+		}{
+			{"start", 0, traj.Waypoints[0].Position},
+			{"second waypoint", leg1, traj.Waypoints[1].Position},
+			{"middle of first leg", leg1 / 2, Position{Longitude: 0.5, Altitude: 500}},
+			{"middle of second leg", leg1 + leg2/2, Position{Longitude: 1.5, Altitude: 500}},
+		} {
+			pos, _ := traj.At(trajectoryStart.Add(seconds(c.elapsed)))
+			if !samePosition(pos, c.want) {
+				t.Errorf("%s: %+v, want %+v", c.name, pos, c.want)
+			}
+		}
+	})
+
+	t.Run("outside the trajectory", func(t *testing.T) {
+		pos, v := traj.At(trajectoryStart.Add(-time.Minute))
+		if pos != traj.Waypoints[0].Position || v != (Velocity{}) {
+			t.Errorf("before start: %+v %+v", pos, v)
+		}
+		pos, v = traj.At(trajectoryStart.Add(seconds(leg1 + leg2 + 60)))
+		if pos != traj.Waypoints[2].Position || v != (Velocity{}) {
+			t.Errorf("after end: %+v %+v", pos, v)
+		}
+	})
+
+	t.Run("velocity at endpoints", func(t *testing.T) {
+		// 端点处只在同一段航线内差分，速度等于该段的速度
+		for _, c := range []struct {
+			name    string
+			elapsed float64
+			want    float64
+		}{
+			{"start", 0, 100},
+			{"end of first leg", leg1 - 0.1, 100},
+			{"start of second leg", leg1 + 0.1, 200},
+			{"end of second leg", leg1 + leg2 - 0.1, 200},
+		} {
+			_, v := traj.At(trajectoryStart.Add(seconds(c.elapsed)))
+			if got := speed(v); math.Abs(got-c.want) > 0.01*c.want {
+				t.Errorf("%s: speed %g m/s, want %g", c.name, got, c.want)
+			}
+		}
+		// 沿赤道向东，速度方向为 ECEF 的 +Y
+		_, v := traj.At(trajectoryStart)
+		if v.Y <= 0 || math.Abs(v.X) > 1 || math.Abs(v.Z) > 1e-6 {
+			t.Errorf("velocity at start %+v, want eastward", v)
+		}
+	})
+
+	t.Run("loop wrap", func(t *testing.T) {
+		loop := equatorTrajectory(t, true)
+		// 最后一个航路点以 200 m/s 返回起点
+		back := 2 * math.Pi / 180 * meanEarthRadius / 200
+		if got, want := loop.Duration().Seconds(), leg1+leg2+back; math.Abs(got-want) > 1e-6 {
+			t.Fatalf("loop duration %g s, want %g s", got, want)
+		}
+		for _, elapsed := range []float64{1, leg1 / 3, leg1 + leg2 + 1, leg1 + leg2 + back/2} {
+			p0, v0 := loop.At(trajectoryStart.Add(seconds(elapsed)))
+			p1, v1 := loop.At(trajectoryStart.Add(loop.Duration()).Add(seconds(elapsed)))
+			if !samePosition(p0, p1) || math.Abs(speed(v0)-speed(v1)) > 1e-6 {
+				t.Errorf("elapsed %g s: %+v after one loop, want %+v", elapsed, p1, p0)
+			}
+		}
+		pos, v := loop.At(trajectoryStart.Add(seconds(leg1 + leg2 + back/2)))
+		if !samePosition(pos, Position{Longitude: 1}) || math.Abs(speed(v)-200) > 2 {
+			t.Errorf("halfway back: %+v at %g m/s", pos, speed(v))
+		}
+	})
+}
+
+func TestNewTrajectoryErrors(t *testing.T) {
+	if _, err := NewTrajectory(trajectoryStart, nil, false); err == nil {
+		t.Error("no waypoints: want error")
+	}
+	_, err := NewTrajectory(trajectoryStart, []Waypoint{
+		{Position: Position{Longitude: 0}},
+		{Position: Position{Longitude: 1}},
+	}, false)
+	if err == nil {
+		t.Error("zero speed on a leg: want error")
+	}
+}
+
+func writeTrajectoryFile(t *testing.T, content string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "mobile.txt")
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestReadMobileStations(t *testing.T) {
+	name := writeTrajectoryFile(t, `# comment
+terminal ship
+31.2 121.4 20 10
+1.2 103.8 20 0
+
+terminal vehicle loop
+31.2 121.4 5 25
+30.2 120.1 10 25
+`)
+	stations, err := ReadMobileStations(name, trajectoryStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 2 {
+		t.Fatalf("%d stations, want 2", len(stations))
+	}
+	if stations[0].Type != TerminalShip || stations[0].Trajectory.Loop {
+		t.Errorf("first terminal: %v loop=%v", stations[0].Type, stations[0].Trajectory.Loop)
+	}
+	if stations[1].Type != TerminalVehicle || !stations[1].Trajectory.Loop {
+		t.Errorf("second terminal: %v loop=%v", stations[1].Type, stations[1].Trajectory.Loop)
+	}
+	if pos := stations[0].GetPosition(trajectoryStart); pos != (Position{Latitude: 31.2, Longitude: 121.4, Altitude: 20}) {
+		t.Errorf("initial position %+v", pos)
+	}
+
+	for _, c := range []struct {
+		name, content, want string
+	}{
+		{"waypoint before terminal", "31.2 121.4 20 10\n", "缺少 terminal"},
+		{"terminal without type", "terminal\n31.2 121.4 20 10\n", "缺少类型"},
+		{"unknown type", "terminal submarine\n31.2 121.4 20 10\n", "unknown terminal type"},
+		{"too few fields", "terminal ship\n31.2 121.4 20\n", "格式为"},
+		{"bad number", "terminal ship\n31.2 east 20 10\n", "格式错误"},
+		{"zero speed", "terminal ship\n31.2 121.4 20 0\n1.2 103.8 20 0\n", "speed must be positive"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ReadMobileStations(writeTrajectoryFile(t, c.content), trajectoryStart)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("error %v, want it to mention %q", err, c.want)
+			}
+		})
+	}
+
+	if _, err := ReadMobileStations(filepath.Join(t.TempDir(), "missing.txt"), trajectoryStart); err == nil {
+		t.Error("missing file: want error")
+	}
+}
+
+func TestMobileStationSameRound(t *testing.T) {
+	sats, _ := testNodes()
+	inst, err := newEmulationInstance(nil, sats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.SetClock(NewSimulatedClock(trajectoryStart, time.Minute))
+	mobile, err := inst.LoadMobileStations(writeTrajectoryFile(t, "terminal aircraft\n40 116 10000 250\n50 8 10000 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := trajectoryStart.Add(30 * time.Minute)
+	if err := inst.CalculateLinks(ts, LinkUpdatePayload{}); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := mobile[0].Trajectory.At(ts)
+	if got := mobile[0].GetPosition(ts); got != want || mobile[0].position != want {
+		t.Errorf("station position %+v, want %+v", mobile[0].position, want)
+	}
+	for _, link := range inst.Links {
+		_, _, r := lookAngles(want, link.SrcNode.(*Satellite).Position)
+		if math.Abs(link.Range-r) > 1e-6 {
+			t.Errorf("link range %g m, want %g m from positions at the same time", link.Range, r)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package itur
+
+import (
+	"demokubenet/utils"
+	"math"
+)
+
+// itu840
+
+// CloudSpecificAttenuationCoefficient 云中液态水的比衰减系数 Kl ((dB/km)/(g/m3))，
+// 由双 Debye 模型计算水的介电常数
+// f: 频率 (GHz), T: 液态水温度 (K)
+func CloudSpecificAttenuationCoefficient(f, T float64) float64 {
+	theta := 300.0 / T
+	eps0 := 77.66 + 103.3*(theta-1)
+	eps1 := 0.0671 * eps0
+	eps2 := 3.52
+	fp := 20.20 - 146*(theta-1) + 316*(theta-1)*(theta-1)
+	fs := 39.8 * fp
+
+	epsI := f*(eps0-eps1)/(fp*(1+math.Pow(f/fp, 2))) + f*(eps1-eps2)/(fs*(1+math.Pow(f/fs, 2)))
+	epsR := (eps0-eps1)/(1+math.Pow(f/fp, 2)) + (eps1-eps2)/(1+math.Pow(f/fs, 2)) + eps2
+	eta := (2 + epsR) / epsI
+
+	return 0.819 * f / (epsI * (1 + eta*eta))
+}
+
+// CloudAttenuation 倾斜路径上的云衰减 (dB)
+// f: 频率 (GHz), el: 仰角 (度), L: 云中液态水柱含量 (kg/m2)
+func CloudAttenuation(f, el, L float64) float64 {
+	// 仰角低于 5 度时公式不再适用，按 5 度计算
+	el = math.Max(el, 5)
+	return L * CloudSpecificAttenuationCoefficient(f, 273.15) / math.Sin(utils.DegToRad(el))
+}
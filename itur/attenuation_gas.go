@@ -0,0 +1,133 @@
+package itur
+
+import (
+	"demokubenet/utils"
+	"math"
+)
+
+// itu676 附件 2 的近似算法 (P.676-10)，适用于 1-350 GHz
+
+func phi676(rp, rt, a, b, c, d float64) float64 {
+	return math.Pow(rp, a) * math.Pow(rt, b) * math.Exp(c*(1-rp)+d*(1-rt))
+}
+
+// GammaOxygen 干空气的比衰减 (dB/km)
+// f: 频率 (GHz), P: 干空气压强 (hPa), T: 温度 (°C)
+func GammaOxygen(f, P, T float64) float64 {
+	rp := P / 1013.0
+	rt := 288.0 / (273.0 + T)
+
+	xi1 := phi676(rp, rt, 0.0717, -1.8132, 0.0156, -1.6515)
+	xi2 := phi676(rp, rt, 0.5146, -4.6368, -0.1921, -5.7416)
+	xi3 := phi676(rp, rt, 0.3414, -6.5851, 0.2130, -8.5854)
+	xi4 := phi676(rp, rt, -0.0112, 0.0092, -0.1033, -0.0009)
+	xi5 := phi676(rp, rt, 0.2705, -2.7192, -0.3016, -4.1033)
+	xi6 := phi676(rp, rt, 0.2445, -5.9191, 0.0422, -8.0719)
+	xi7 := phi676(rp, rt, -0.1833, 6.5589, -0.2402, 6.131)
+
+	gamma54 := 2.192 * phi676(rp, rt, 1.8286, -1.9487, 0.4051, -2.8509)
+	gamma58 := 12.59 * phi676(rp, rt, 1.0045, 3.5610, 0.1588, 1.2834)
+	gamma60 := 15.0 * phi676(rp, rt, 0.9003, 4.1335, 0.0427, 1.6088)
+	gamma62 := 14.28 * phi676(rp, rt, 0.9886, 3.4176, 0.1827, 1.3429)
+	gamma64 := 6.819 * phi676(rp, rt, 1.4320, 0.6258, 0.3177, -0.5914)
+	gamma66 := 1.908 * phi676(rp, rt, 2.0717, -4.1404, 0.4910, -4.8718)
+	delta := -0.00306 * phi676(rp, rt, 3.211, -14.94, 1.583, -16.37)
+
+	switch {
+	case f <= 54:
+		return (7.2*math.Pow(rt, 2.8)/(f*f+0.34*rp*rp*math.Pow(rt, 1.6)) +
+			0.62*xi3/(math.Pow(54-f, 1.16*xi1)+0.83*xi2)) * f * f * rp * rp * 1e-3
+	case f <= 60:
+		return math.Exp(math.Log(gamma54)/24*(f-58)*(f-60) -
+			math.Log(gamma58)/8*(f-54)*(f-60) +
+			math.Log(gamma60)/12*(f-54)*(f-58))
+	case f <= 62:
+		return gamma60 + (gamma62-gamma60)*(f-60)/2
+	case f <= 66:
+		return math.Exp(math.Log(gamma62)/8*(f-64)*(f-66) -
+			math.Log(gamma64)/4*(f-62)*(f-66) +
+			math.Log(gamma66)/8*(f-62)*(f-64))
+	case f <= 120:
+		return (3.02e-4*math.Pow(rt, 3.5) +
+			0.283*math.Pow(rt, 3.8)/(math.Pow(f-118.75, 2)+2.91*rp*rp*math.Pow(rt, 1.6)) +
+			0.502*xi6*(1-0.0163*xi7*(f-66))/(math.Pow(f-66, 1.4346*xi4)+1.15*xi5)) * f * f * rp * rp * 1e-3
+	default:
+		return (3.02e-4/(1+1.9e-5*math.Pow(f, 1.5))+
+			0.283*math.Pow(rt, 0.3)/(math.Pow(f-118.75, 2)+2.91*rp*rp*math.Pow(rt, 1.6)))*
+			f*f*rp*rp*math.Pow(rt, 3.5)*1e-3 + delta
+	}
+}
+
+// GammaWaterVapour 水汽的比衰减 (dB/km)
+// f: 频率 (GHz), P: 压强 (hPa), rho: 水汽密度 (g/m3), T: 温度 (°C)
+func GammaWaterVapour(f, P, rho, T float64) float64 {
+	rp := P / 1013.0
+	rt := 288.0 / (273.0 + T)
+	eta1 := 0.955*rp*math.Pow(rt, 0.68) + 0.006*rho
+	eta2 := 0.735*rp*math.Pow(rt, 0.5) + 0.0353*math.Pow(rt, 4)*rho
+
+	g := func(fi float64) float64 {
+		return 1 + math.Pow((f-fi)/(f+fi), 2)
+	}
+	line := func(a, b, fi, w float64) float64 {
+		return a * eta1 * math.Exp(b*(1-rt)) / (math.Pow(f-fi, 2) + w*eta1*eta1)
+	}
+
+	return (line(3.98, 2.23, 22.235, 9.42)*g(22) +
+		line(11.96, 0.7, 183.31, 11.14) +
+		line(0.081, 6.44, 321.226, 6.29) +
+		line(3.66, 1.6, 325.153, 9.22) +
+		25.37*eta1*math.Exp(1.09*(1-rt))/math.Pow(f-380, 2) +
+		17.4*eta1*math.Exp(1.46*(1-rt))/math.Pow(f-448, 2) +
+		844.6*eta1*math.Exp(0.17*(1-rt))/math.Pow(f-557, 2)*g(557) +
+		290*eta1*math.Exp(0.41*(1-rt))/math.Pow(f-752, 2)*g(752) +
+		8.3328e4*eta2*math.Exp(0.99*(1-rt))/math.Pow(f-1780, 2)*g(1780)) *
+		f * f * math.Pow(rt, 2.5) * rho * 1e-4
+}
+
+// EquivalentHeights 干空气和水汽的等效高度 (km)
+func EquivalentHeights(f, P float64) (float64, float64) {
+	rp := P / 1013.0
+
+	t1 := 4.64 / (1 + 0.066*math.Pow(rp, -2.3)) *
+		math.Exp(-math.Pow((f-59.7)/(2.87+12.4*math.Exp(-7.9*rp)), 2))
+	t2 := 0.14 * math.Exp(2.12*rp) / (math.Pow(f-118.75, 2) + 0.031*math.Exp(2.2*rp))
+	t3 := 0.0114 / (1 + 0.14*math.Pow(rp, -2.6)) * f *
+		(-0.0247 + 0.0001*f + 1.61e-6*f*f) / (1 - 0.0169*f + 4.1e-5*f*f + 3.2e-7*f*f*f)
+	ho := 6.1 / (1 + 0.17*math.Pow(rp, -1.1)) * (1 + t1 + t2 + t3)
+	if f < 70 {
+		ho = math.Min(ho, 10.7*math.Pow(rp, 0.3))
+	}
+
+	sigmaw := 1.013 / (1 + math.Exp(-8.6*(rp-0.57)))
+	hw := 1.66 * (1 + 1.39*sigmaw/(math.Pow(f-22.235, 2)+2.56*sigmaw) +
+		3.37*sigmaw/(math.Pow(f-183.31, 2)+4.69*sigmaw) +
+		1.58*sigmaw/(math.Pow(f-325.1, 2)+2.89*sigmaw))
+
+	return ho, hw
+}
+
+// GaseousAttenuationSlantPath 倾斜路径上的气体衰减 (dB)
+// f: 频率 (GHz), el: 仰角 (度), rho: 水汽密度 (g/m3), P: 总压强 (hPa), T: 温度 (°C)
+func GaseousAttenuationSlantPath(f, el, rho, P, T float64) float64 {
+	// 氧气的比衰减使用干空气压强，即总压强减去水汽压 e，见 itu453
+	e := rho * (T + 273.15) / 216.7
+	gammao := GammaOxygen(f, P-e, T)
+	gammaw := GammaWaterVapour(f, P, rho, T)
+	ho, hw := EquivalentHeights(f, P)
+
+	if el >= 5 {
+		return (ho*gammao + hw*gammaw) / math.Sin(utils.DegToRad(el))
+	}
+
+	// 低仰角时考虑地球曲率
+	Re := 8500.0
+	tanEl := math.Tan(utils.DegToRad(math.Max(el, 0)))
+	F := func(x float64) float64 {
+		return 1 / (0.661*x + 0.339*math.Sqrt(x*x+5.51))
+	}
+	cosEl := math.Cos(utils.DegToRad(math.Max(el, 0)))
+	Ao := gammao * math.Sqrt(Re*ho) * F(tanEl*math.Sqrt(Re/ho)) / cosEl
+	Aw := gammaw * math.Sqrt(Re*hw) * F(tanEl*math.Sqrt(Re/hw)) / cosEl
+	return Ao + Aw
+}
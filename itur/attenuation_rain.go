@@ -79,21 +79,27 @@ func RainSpecificAttenuationCoefficients(f, el, tau float64) (float64, float64)
 const EPSILON = 1e-9
 
 // 地面的经纬度lat, lon, 频率，倾角，地面站高度，不可用度，极化倾角，路径长度
+// tau: 极化倾角 (度)，水平极化为 0，垂直极化为 90，圆极化为 45
+// Ls: 雨高以下的倾斜路径长度 (km)，为 0 时由雨高和仰角计算
 // itu618
 func RainAttenuation(lat, lon, f, el, hs, p, R001, tau, Ls float64) float64 {
 	// fmt.Println("attenuation rain")
-	tau = 45
 	Re := 8500.0 //地球半径km
 
-	// step 1: hr降雨高度取 4km
-	hr := 4.0
+	// step 1: hr降雨高度，itu839
+	hr := RainHeight(lat, lon)
+	if hr-hs <= 0 {
+		return 0
+	}
 
-	// step 2: Ls
-	if el >= 5 {
-		Ls = (hr - hs) / math.Sin(utils.DegToRad(el))
-	} else {
-		sinEl := math.Sin(utils.DegToRad(el))
-		Ls = 2 * (hr - hs) / (math.Sqrt(math.Pow(sinEl, 2)+2*(hr-hs)/Re) + sinEl)
+	// step 2: Ls，调用方给出时直接使用
+	if Ls <= 0 {
+		if el >= 5 {
+			Ls = (hr - hs) / math.Sin(utils.DegToRad(el))
+		} else {
+			sinEl := math.Sin(utils.DegToRad(el))
+			Ls = 2 * (hr - hs) / (math.Sqrt(math.Pow(sinEl, 2)+2*(hr-hs)/Re) + sinEl)
+		}
 	}
 
 	// Step 3: Lg
@@ -109,7 +115,7 @@ func RainAttenuation(lat, lon, f, el, hs, p, R001, tau, Ls float64) float64 {
 
 	// log.Println("r001:", r001)
 	//step 7
-	eta := utils.DegToRad(math.Atan2(hr-hs, Lg*r001))
+	eta := utils.RadToDeg(math.Atan2(hr-hs, Lg*r001))
 	Delta_h := math.Max(hr-hs, EPSILON)
 	Lr := 0.0
 	if eta > el {
@@ -131,6 +137,9 @@ func RainAttenuation(lat, lon, f, el, hs, p, R001, tau, Ls float64) float64 {
 
 	// step 9:
 	A001 := gammar * Le
+	if A001 <= 0 {
+		return 0
+	}
 
 	// step 10:
 	beta := 0.0
@@ -138,10 +147,10 @@ func RainAttenuation(lat, lon, f, el, hs, p, R001, tau, Ls float64) float64 {
 		beta = 0.0
 	} else if math.Abs(lat) >= 36 {
 		beta = 0.0
-	} else if el > 25 {
-		beta = -0.005*(math.Abs(lat)-36) + 1.8 - 4.25*math.Sin(utils.DegToRad(el))
-	} else {
+	} else if el >= 25 {
 		beta = -0.005 * (math.Abs(lat) - 36)
+	} else {
+		beta = -0.005*(math.Abs(lat)-36) + 1.8 - 4.25*math.Sin(utils.DegToRad(el))
 	}
 
 	A := A001 * math.Pow(p/0.01, -(0.655+0.033*math.Log(p)-0.045*math.Log(A001)-beta*(1-p)*math.Sin(utils.DegToRad(el))))
@@ -0,0 +1,157 @@
+package itur
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// ITU-R SG3 的验证算例依赖官方 P.837/P.839 数字地图，包内没有这些数据，
+// 这里校验 P.838-3 表 5 的系数、雨衰公式的性质，以及 h0 取常数时逐步计算的算例
+
+// P.838-3 表 5
+var coefficientCases = []struct {
+	f          float64
+	kH, alphaH float64
+	kV, alphaV float64
+}{
+	{1, 0.0000259, 0.9691, 0.0000308, 0.8592},
+	{2, 0.0000847, 1.0664, 0.0000998, 0.9490},
+	{4, 0.0001071, 1.6009, 0.0002461, 1.2476},
+	{6, 0.0007056, 1.5900, 0.0004878, 1.5728},
+	{8, 0.004115, 1.3905, 0.003450, 1.3797},
+	{10, 0.01217, 1.2571, 0.01129, 1.2156},
+	{12, 0.02386, 1.1825, 0.02455, 1.1216},
+	{15, 0.04481, 1.1233, 0.05008, 1.0440},
+	{20, 0.09164, 1.0568, 0.09611, 0.9847},
+	{25, 0.1571, 0.9991, 0.1533, 0.9491},
+	{30, 0.2403, 0.9485, 0.2291, 0.9129},
+	{35, 0.3374, 0.9047, 0.3224, 0.8761},
+	{40, 0.4431, 0.8673, 0.4274, 0.8421},
+	{50, 0.6600, 0.8084, 0.6472, 0.7871},
+	{60, 0.8606, 0.7656, 0.8515, 0.7486},
+}
+
+// checkRel 检查 got 与 want 的相对误差不超过 tol
+func checkRel(t *testing.T, name string, got, want, tol float64) {
+	t.Helper()
+	if math.IsNaN(got) || math.Abs(got-want) > tol*math.Abs(want) {
+		t.Errorf("%s: got %.7g, want %.7g (tolerance %.2g%%)", name, got, want, tol*100)
+	}
+}
+
+func TestRainSpecificAttenuationCoefficients(t *testing.T) {
+	for _, c := range coefficientCases {
+		t.Run(fmt.Sprintf("f=%g", c.f), func(t *testing.T) {
+			// 表中系数保留 4 位有效数字；仰角 0 时水平极化 tau=0 得到 kH，垂直极化 tau=90 得到 kV
+			kH, alphaH := RainSpecificAttenuationCoefficients(c.f, 0, 0)
+			kV, alphaV := RainSpecificAttenuationCoefficients(c.f, 0, 90)
+			checkRel(t, "kH", kH, c.kH, 1e-3)
+			checkRel(t, "alphaH", alphaH, c.alphaH, 1e-3)
+			checkRel(t, "kV", kV, c.kV, 1e-3)
+			checkRel(t, "alphaV", alphaV, c.alphaV, 1e-3)
+		})
+	}
+}
+
+func TestRainHeightZonal(t *testing.T) {
+	// 没有读入 P.839-4 数字地图时使用 P.839-1 的模型
+	for _, c := range []struct{ lat, want float64 }{
+		{0, 5}, {23, 5}, {-21, 5}, {43, 3.5}, {-41, 3}, {-80, 0.36},
+	} {
+		t.Run(fmt.Sprintf("lat=%g", c.lat), func(t *testing.T) {
+			checkRel(t, "hR", RainHeight(c.lat, 120), c.want, 1e-9)
+		})
+	}
+}
+
+func TestRainAttenuation(t *testing.T) {
+	t.Run("no rain", func(t *testing.T) {
+		for _, p := range []float64{0.001, 0.01, 0.1, 1} {
+			if a := RainAttenuation(40, -3, 20, 30, 0.1, p, 0, 45, 0); a != 0 {
+				t.Errorf("p=%g: got %g, want 0", p, a)
+			}
+		}
+	})
+
+	t.Run("above rain height", func(t *testing.T) {
+		if a := RainAttenuation(80, 0, 20, 30, 2, 0.01, 50, 45, 0); a != 0 {
+			t.Errorf("got %g, want 0", a)
+		}
+	})
+
+	t.Run("increases with R001", func(t *testing.T) {
+		prev := 0.0
+		for _, R := range []float64{5, 10, 20, 50, 100} {
+			a := RainAttenuation(40, -3, 20, 30, 0.1, 0.01, R, 45, 0)
+			if a <= prev {
+				t.Errorf("R001=%g: %g not above %g", R, a, prev)
+			}
+			prev = a
+		}
+	})
+
+	t.Run("decreases with p", func(t *testing.T) {
+		prev := math.Inf(1)
+		for _, p := range []float64{0.001, 0.01, 0.1, 1, 5} {
+			a := RainAttenuation(40, -3, 20, 30, 0.1, p, 50, 45, 0)
+			if a >= prev {
+				t.Errorf("p=%g: %g not below %g", p, a, prev)
+			}
+			prev = a
+		}
+	})
+
+	t.Run("polarization", func(t *testing.T) {
+		h := RainAttenuation(40, -3, 30, 10, 0.1, 0.01, 50, 0, 0)
+		c := RainAttenuation(40, -3, 30, 10, 0.1, 0.01, 50, 45, 0)
+		v := RainAttenuation(40, -3, 30, 10, 0.1, 0.01, 50, 90, 0)
+		if !(h > c && c > v) {
+			t.Errorf("horizontal %g, circular %g, vertical %g", h, c, v)
+		}
+	})
+
+	t.Run("slant path length", func(t *testing.T) {
+		// 给出的 Ls 与内部计算的一致时结果不变，更长的路径衰减更大
+		Ls := (RainHeight(40, -3) - 0.1) / math.Sin(30*math.Pi/180)
+		checkRel(t, "Ls", RainAttenuation(40, -3, 20, 30, 0.1, 0.01, 50, 45, Ls), RainAttenuation(40, -3, 20, 30, 0.1, 0.01, 50, 45, 0), 1e-9)
+		if RainAttenuation(40, -3, 20, 30, 0.1, 0.01, 50, 45, 2*Ls) <= RainAttenuation(40, -3, 20, 30, 0.1, 0.01, 50, 45, Ls) {
+			t.Error("longer Ls does not increase attenuation")
+		}
+	})
+}
+
+// 逐步计算的 P.618-13 2.2.1.1 算例：h0 取常数，系数取 P.838-3 表 5 并按式 (4)、(5) 换算极化，
+// A001 和 Ap 由独立于本包的逐步计算得到。不是 SG3 的验证算例；有了官方算例后按同样的列加入
+var rainAttenuationCases = []struct {
+	lat, h0, hs   float64 // 度, km, km
+	f, el, tau, p float64 // GHz, 度, 度, %
+	R001          float64 // mm/h
+	A001, Ap      float64 // dB
+}{
+	// |lat| >= 36，beta = 0
+	{lat: 51.5, h0: 3.2, hs: 0.05, f: 20, el: 35, tau: 45, p: 0.1, R001: 30, A001: 16.1375, Ap: 5.67509},
+	// p = 0.01 时 Ap = A001
+	{lat: 10, h0: 4.6, hs: 0.2, f: 30, el: 40, tau: 90, p: 0.01, R001: 60, A001: 56.1651, Ap: 56.1651},
+	// |lat| < 36 且仰角低于 25 度，beta 含仰角项
+	{lat: -25, h0: 4.0, hs: 0.1, f: 12, el: 15, tau: 0, p: 0.5, R001: 45, A001: 16.4748, Ap: 3.33478},
+	// 仰角低于 5 度时按地球曲率计算 Ls，p >= 1 时 beta = 0
+	{lat: 45, h0: 2.8, hs: 0.3, f: 20, el: 4, tau: 45, p: 1, R001: 25, A001: 41.6568, Ap: 4.41915},
+}
+
+func TestRainAttenuationCases(t *testing.T) {
+	t.Cleanup(func() { setIsothermHeightGrid(nil) })
+	for _, c := range rainAttenuationCases {
+		t.Run(fmt.Sprintf("lat=%g,f=%g,el=%g,p=%g", c.lat, c.f, c.el, c.p), func(t *testing.T) {
+			grid := fmt.Sprintf("-90 180 2 0 180 3\n%[1]g %[1]g %[1]g\n%[1]g %[1]g %[1]g\n", c.h0)
+			if err := LoadIsothermHeightGrid(strings.NewReader(grid)); err != nil {
+				t.Fatal(err)
+			}
+			checkRel(t, "hR", RainHeight(c.lat, 30), c.h0+0.36, 1e-12)
+			// 包内的系数是 P.838-3 的回归式，与表 5 的 4 位有效数字相差约 0.1%
+			checkRel(t, "A0.01", RainAttenuation(c.lat, 30, c.f, c.el, c.hs, 0.01, c.R001, c.tau, 0), c.A001, 2e-3)
+			checkRel(t, "Ap", RainAttenuation(c.lat, 30, c.f, c.el, c.hs, c.p, c.R001, c.tau, 0), c.Ap, 2e-3)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package itur
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClearSkyAttenuation(t *testing.T) {
+	t.Run("oxygen 60 GHz band", func(t *testing.T) {
+		if g := GammaOxygen(60, 1013, 15); g <= 10 {
+			t.Errorf("gamma_o(60 GHz) = %g dB/km, want > 10", g)
+		}
+	})
+
+	t.Run("water vapour 22.235 GHz line", func(t *testing.T) {
+		line := GammaWaterVapour(22.235, 1013, 7.5, 15)
+		if line <= GammaWaterVapour(18, 1013, 7.5, 15) || line <= GammaWaterVapour(26, 1013, 7.5, 15) {
+			t.Errorf("gamma_w(22.235 GHz) = %g is not a peak", line)
+		}
+	})
+
+	t.Run("slant path continuous at 5 deg", func(t *testing.T) {
+		// 低仰角公式与 5 度处的余割公式基本连续
+		checkRel(t, "Ag", GaseousAttenuationSlantPath(20, 4.999, 7.5, 1013, 15), GaseousAttenuationSlantPath(20, 5, 7.5, 1013, 15), 5e-2)
+	})
+
+	t.Run("cloud proportional to L", func(t *testing.T) {
+		checkRel(t, "Ac", CloudAttenuation(30, 30, 1), 2*CloudAttenuation(30, 30, 0.5), 1e-9)
+	})
+
+	t.Run("scintillation decreases with antenna size", func(t *testing.T) {
+		if ScintillationAttenuation(20, 20, 1, 0.6, 0.5, 15, 70, 1000) <= ScintillationAttenuation(20, 20, 1, 2.4, 0.5, 15, 70, 1000) {
+			t.Error("larger antenna does not reduce scintillation")
+		}
+	})
+}
+
+func TestCloudLiquidWater(t *testing.T) {
+	t.Cleanup(func() {
+		cloudLiquidWaterMu.Lock()
+		cloudLiquidWaterMaps = map[float64]*Grid{}
+		cloudLiquidWaterMu.Unlock()
+	})
+	constant := func(v string) *strings.Reader {
+		return strings.NewReader("90 -180 2 0 360 2\n" + v + " " + v + "\n" + v + " " + v + "\n")
+	}
+
+	if _, ok := CloudLiquidWater(30, 120, 5); ok {
+		t.Fatal("no maps loaded: want ok = false")
+	}
+	if err := LoadCloudLiquidWaterGrid(7, constant("1")); err == nil {
+		t.Error("p = 7%: want error, no such map")
+	}
+	if err := LoadCloudLiquidWaterGrid(5, constant("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadCloudLiquidWaterGrid(10, constant("0.5")); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ p, want float64 }{{1, 1}, {5, 1}, {10, 0.5}, {50, 0.5}} {
+		if L, _ := CloudLiquidWater(30, 120, c.p); L != c.want {
+			t.Errorf("p=%g: L = %g, want %g", c.p, L, c.want)
+		}
+	}
+	// 两张地图之间按 ln p 插值
+	L, _ := CloudLiquidWater(30, 120, 7)
+	checkRel(t, "L(7%)", L, 0.757286586, 1e-8)
+}
@@ -0,0 +1,73 @@
+package itur
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+// itu840 云中液态水柱含量的年统计
+
+// P.840 数字地图给出的时间百分比 (%)
+var cloudLiquidWaterProbabilities = []float64{0.1, 0.2, 0.3, 0.5, 1, 2, 3, 5, 10, 20, 30, 50, 60, 70, 80, 90, 95, 99}
+
+var (
+	cloudLiquidWaterMu   sync.RWMutex
+	cloudLiquidWaterMaps = map[float64]*Grid{}
+)
+
+// LoadCloudLiquidWaterGrid 读入年平均时间的 p% 被超过的、换算到 0°C 的液态水柱含量 Lred (kg/m2) 网格，
+// 例如 ITU 发布的 P.840-8 数字地图，p 必须是数字地图给出的时间百分比之一
+func LoadCloudLiquidWaterGrid(p float64, r io.Reader) error {
+	i := sort.SearchFloat64s(cloudLiquidWaterProbabilities, p)
+	if i == len(cloudLiquidWaterProbabilities) || cloudLiquidWaterProbabilities[i] != p {
+		return fmt.Errorf("P.840 Lred: no digital map for p = %g%%", p)
+	}
+	g, err := ParseGrid(r)
+	if err != nil {
+		return fmt.Errorf("P.840 Lred %g%%: %w", p, err)
+	}
+	cloudLiquidWaterMu.Lock()
+	defer cloudLiquidWaterMu.Unlock()
+	cloudLiquidWaterMaps[p] = g
+	return nil
+}
+
+// CloudLiquidWater 在 (lat, lon) 处年平均时间的 p% 被超过的液态水柱含量 Lred (kg/m2)。
+// p 介于两张已读入的地图之间时按 ln p 线性插值，超出范围时取最近的地图；
+// 没有读入任何地图时 ok 为 false
+func CloudLiquidWater(lat, lon, p float64) (L float64, ok bool) {
+	cloudLiquidWaterMu.RLock()
+	defer cloudLiquidWaterMu.RUnlock()
+
+	var below, above float64
+	for _, q := range cloudLiquidWaterProbabilities {
+		if cloudLiquidWaterMaps[q] == nil {
+			continue
+		}
+		if q <= p {
+			below = q
+		}
+		if q >= p && above == 0 {
+			above = q
+		}
+	}
+	switch {
+	case below == 0 && above == 0:
+		return 0, false
+	case below == 0:
+		below = above
+	case above == 0:
+		above = below
+	}
+
+	lo := cloudLiquidWaterMaps[below].Interpolate(lat, lon)
+	if below == above {
+		return lo, true
+	}
+	hi := cloudLiquidWaterMaps[above].Interpolate(lat, lon)
+	w := (math.Log(p) - math.Log(below)) / (math.Log(above) - math.Log(below))
+	return lo + (hi-lo)*w, true
+}
@@ -0,0 +1,168 @@
+package itur
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Grid 规则经纬度网格上的数据，用于 ITU-R 的数字地图
+// 文件格式：'#' 开头的行为注释，第一行数据为 "lat0 dlat nlat lon0 dlon nlon"，
+// 之后 nlat 行，每行 nlon 个值，第 i 行第 j 列对应纬度 lat0+i*dlat、经度 lon0+j*dlon
+type Grid struct {
+	Lat0, DLat float64
+	Lon0, DLon float64
+	Values     [][]float64
+}
+
+func ParseGrid(r io.Reader) (*Grid, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var g Grid
+	var nlat, nlon int
+	header := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if !header {
+			if _, err := fmt.Sscan(line, &g.Lat0, &g.DLat, &nlat, &g.Lon0, &g.DLon, &nlon); err != nil {
+				return nil, fmt.Errorf("网格头格式错误: %w", err)
+			}
+			header = true
+			continue
+		}
+		if len(fields) != nlon {
+			return nil, fmt.Errorf("网格第 %d 行有 %d 个值，期望 %d", len(g.Values)+1, len(fields), nlon)
+		}
+		row := make([]float64, nlon)
+		for j, field := range fields {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("网格第 %d 行: %w", len(g.Values)+1, err)
+			}
+			row[j] = v
+		}
+		g.Values = append(g.Values, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, fmt.Errorf("网格缺少头")
+	}
+	if len(g.Values) != nlat || nlat < 2 || nlon < 2 {
+		return nil, fmt.Errorf("网格大小错误: %d 行，期望 %d", len(g.Values), nlat)
+	}
+	return &g, nil
+}
+
+// ParseGridMatrices 读入 ITU 数字地图常用的矩阵格式：纬度、经度和数值分别是一个文件，
+// 三个矩阵形状相同，第 i 行第 j 列共同给出一个网格点，如 P.839-4 的 ESALAT、ESALON 和 ESA0HEIGHT。
+// 纬度沿行、经度沿列等间隔，纬度可以从北向南递减
+func ParseGridMatrices(lat, lon, values io.Reader) (*Grid, error) {
+	lats, err := parseMatrix(lat)
+	if err != nil {
+		return nil, fmt.Errorf("纬度矩阵: %w", err)
+	}
+	lons, err := parseMatrix(lon)
+	if err != nil {
+		return nil, fmt.Errorf("经度矩阵: %w", err)
+	}
+	vals, err := parseMatrix(values)
+	if err != nil {
+		return nil, fmt.Errorf("数值矩阵: %w", err)
+	}
+	nlat, nlon := len(vals), len(vals[0])
+	if nlat < 2 || nlon < 2 {
+		return nil, fmt.Errorf("网格大小错误: %d x %d", nlat, nlon)
+	}
+	if len(lats) != nlat || len(lons) != nlat || len(lats[0]) != nlon || len(lons[0]) != nlon {
+		return nil, fmt.Errorf("纬度 %d x %d、经度 %d x %d 与数值 %d x %d 的形状不同",
+			len(lats), len(lats[0]), len(lons), len(lons[0]), nlat, nlon)
+	}
+
+	g := &Grid{
+		Lat0: lats[0][0], DLat: lats[1][0] - lats[0][0],
+		Lon0: lons[0][0], DLon: lons[0][1] - lons[0][0],
+		Values: vals,
+	}
+	if g.DLat == 0 || g.DLon <= 0 {
+		return nil, fmt.Errorf("网格间隔错误: dlat %g, dlon %g", g.DLat, g.DLon)
+	}
+	const tol = 1e-6
+	for i := 0; i < nlat; i++ {
+		for j := 0; j < nlon; j++ {
+			if math.Abs(lats[i][j]-(g.Lat0+float64(i)*g.DLat)) > tol || math.Abs(lons[i][j]-(g.Lon0+float64(j)*g.DLon)) > tol {
+				return nil, fmt.Errorf("第 %d 行第 %d 列 (%g, %g) 不在等间隔网格上", i+1, j+1, lats[i][j], lons[i][j])
+			}
+		}
+	}
+	return g, nil
+}
+
+// parseMatrix 读入空白分隔的矩阵，每行的值个数必须相同
+func parseMatrix(r io.Reader) ([][]float64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var m [][]float64
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(m) > 0 && len(fields) != len(m[0]) {
+			return nil, fmt.Errorf("第 %d 行有 %d 个值，期望 %d", len(m)+1, len(fields), len(m[0]))
+		}
+		row := make([]float64, len(fields))
+		for j, field := range fields {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行: %w", len(m)+1, err)
+			}
+			row[j] = v
+		}
+		m = append(m, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("矩阵为空")
+	}
+	return m, nil
+}
+
+// Interpolate 在 (lat, lon) 处双线性插值，经度按 360 度取模，纬度超出网格时取边界值
+func (g *Grid) Interpolate(lat, lon float64) float64 {
+	nlat, nlon := len(g.Values), len(g.Values[0])
+
+	y := (lat - g.Lat0) / g.DLat
+	y = math.Max(0, math.Min(y, float64(nlat-1)))
+	i := int(math.Min(math.Floor(y), float64(nlat-2)))
+	dy := y - float64(i)
+
+	// 覆盖全球经度的网格最后一列与第一列重合
+	span := float64(nlon-1) * g.DLon
+	x := math.Mod(lon-g.Lon0, 360)
+	if x < 0 {
+		x += 360
+	}
+	if x > span {
+		x = span
+	}
+	x /= g.DLon
+	j := int(math.Min(math.Floor(x), float64(nlon-2)))
+	dx := x - float64(j)
+
+	v00, v01 := g.Values[i][j], g.Values[i][j+1]
+	v10, v11 := g.Values[i+1][j], g.Values[i+1][j+1]
+	return v00*(1-dy)*(1-dx) + v01*(1-dy)*dx + v10*dy*(1-dx) + v11*dy*dx
+}
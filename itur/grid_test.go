@@ -0,0 +1,80 @@
+package itur
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// itu839Matrices 按 P.839-4 数字地图的排列生成纬度、经度和数值矩阵：
+// 1.5° 网格，纬度从 90 到 -90，经度从 -180 到 180
+func itu839Matrices(h0 func(lat, lon float64) float64) (lat, lon, values string) {
+	var lb, ob, vb strings.Builder
+	for i := 0; i <= 120; i++ {
+		for j := 0; j <= 240; j++ {
+			la, lo := 90-1.5*float64(i), -180+1.5*float64(j)
+			fmt.Fprintf(&lb, " %g", la)
+			fmt.Fprintf(&ob, " %g", lo)
+			fmt.Fprintf(&vb, " %.6f", h0(la, lo))
+		}
+		lb.WriteString("\n")
+		ob.WriteString("\n")
+		vb.WriteString("\n")
+	}
+	return lb.String(), ob.String(), vb.String()
+}
+
+func resetIsothermHeightGrid(t *testing.T) {
+	t.Cleanup(func() { setIsothermHeightGrid(nil) })
+}
+
+func TestLoadIsothermHeightMatrices(t *testing.T) {
+	resetIsothermHeightGrid(t)
+	// 双线性插值对经纬度的线性函数是精确的
+	h0 := func(lat, lon float64) float64 { return 3 + 0.02*lat + 0.001*lon }
+	lat, lon, values := itu839Matrices(h0)
+	if err := LoadIsothermHeightMatrices(strings.NewReader(lat), strings.NewReader(lon), strings.NewReader(values)); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ lat, lon, want float64 }{
+		{0, 0, 3},
+		{51.2, -0.7, h0(51.2, -0.7)},
+		{-33.9, 151.2, h0(-33.9, 151.2)},
+		{89.9, 179.9, h0(89.9, 179.9)},
+		// 经度按 360 度取模
+		{10, 200, h0(10, -160)},
+	} {
+		checkRel(t, fmt.Sprintf("h0(%g, %g)", c.lat, c.lon), IsothermHeight(c.lat, c.lon), c.want, 1e-6)
+	}
+	checkRel(t, "hR", RainHeight(51.2, -0.7), h0(51.2, -0.7)+0.36, 1e-6)
+
+	// 重新读入网格格式的地图会替换它，清除后回到 P.839-1 的纬度模型
+	if err := LoadIsothermHeightGrid(strings.NewReader("-90 180 2 0 180 3\n4 4 4\n4 4 4\n")); err != nil {
+		t.Fatal(err)
+	}
+	checkRel(t, "constant h0", IsothermHeight(51.2, -0.7), 4, 1e-12)
+	setIsothermHeightGrid(nil)
+	checkRel(t, "zonal h0", IsothermHeight(51.2, -0.7), zonalRainHeight(51.2)-0.36, 1e-12)
+}
+
+func TestParseGridMatricesErrors(t *testing.T) {
+	cases := []struct {
+		name             string
+		lat, lon, values string
+		want             string
+	}{
+		{"empty", "", "0 1\n0 1\n", "0 0\n0 0\n", "矩阵为空"},
+		{"ragged", "1 1\n0\n", "0 1\n0 1\n", "0 0\n0 0\n", "第 2 行有 1 个值"},
+		{"shape", "1 1\n0 0\n", "0 1\n0 1\n", "0 0 0\n0 0 0\n", "形状不同"},
+		{"irregular", "2 2 2\n1 1 1\n", "0 1 3\n0 1 3\n", "0 0 0\n0 0 0\n", "不在等间隔网格上"},
+		{"bad value", "1 1\n0 0\n", "0 1\n0 1\n", "0 x\n0 0\n", "数值矩阵"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseGridMatrices(strings.NewReader(c.lat), strings.NewReader(c.lon), strings.NewReader(c.values))
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("error %v, want %q", err, c.want)
+			}
+		})
+	}
+}
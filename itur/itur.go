@@ -2,15 +2,37 @@ package itur
 
 import (
 	"log"
+	"math"
 )
 
+// Contributions 倾斜路径总衰减的各个分量 (dB)
+type Contributions struct {
+	Gas, Cloud, Rain, Scintillation float64
+}
+
+// Total 按 itu618 2.5 合成总衰减: At = Ag + sqrt((Ar + Ac)^2 + As^2)
+func (c Contributions) Total() float64 {
+	return c.Gas + math.Sqrt(math.Pow(c.Rain+c.Cloud, 2)+c.Scintillation*c.Scintillation)
+}
+
 // 初始化计算雨衰的变量的值
 // 除了 lat, lon, el, f := 22.5, D := 1.2, p := 0.1
 // 即 hs:地面站高度， P：压强， V_t, rho
+// 按 itu618 2.5 合成总衰减: At = Ag + sqrt((Ar + Ac)^2 + As^2)
 func Atmospheric_attenuation_slant_path(
-	lat, lon, f, el, p, D, hs, rho, R001, eta, T, H, P, hL, Ls, tau, V_t float64,
+	lat, lon, f, el, p, D, hs, rho, R001, eta, T, H, P, hL, Ls, tau, V_t, L float64,
 	mode, returnContributions, includeRain, includeGas, includeScintillation, includeClouds bool,
 ) float64 {
+	return SlantPathContributions(lat, lon, f, el, p, D, hs, rho, R001, eta, T, H, P, hL, Ls, tau, V_t, L,
+		includeRain, includeGas, includeScintillation, includeClouds).Total()
+}
+
+// SlantPathContributions 与 Atmospheric_attenuation_slant_path 相同，但分别返回各个分量，
+// 调用方可以替换其中的一项（例如按不同的时间百分比计算的雨衰）后再用 Total 合成
+func SlantPathContributions(
+	lat, lon, f, el, p, D, hs, rho, R001, eta, T, H, P, hL, Ls, tau, V_t, L float64,
+	includeRain, includeGas, includeScintillation, includeClouds bool,
+) Contributions {
 	// f : (GHz)
 	// el : (degree)
 	// p : Percentage of the time the rain attenuation value is exceeded.
@@ -27,14 +49,17 @@ func Atmospheric_attenuation_slant_path(
 	// 		rain height and the elevation angle.
 	// tau: Polarization tilt angle. Default value is 45
 	// V_t: Integrated water vapour content along the path (kg/m2 or mm)
+	// L: Total columnar content of liquid water in clouds (kg/m2). If not provided, it is
+	// 		taken from the P.840 maps loaded with LoadCloudLiquidWaterGrid
 	// mode : Mode for the calculation of gaseous attenuation. 'approx', 'exact'
+	// 		目前只实现了 itu676 附件 2 的近似算法，mode 不影响结果
 	if p < 0.001 || p > 50 {
 		log.Println("Warning: The method to compute the total atmospheric attenuation is only recommended for p between 0.001% and 50%.")
 	}
 	// p_c_g := math.Max(1, p)
 
 	if hs == 0 {
-		hs = 100.0 //m
+		hs = 0.1 // km
 	}
 
 	if P == 0 {
@@ -46,15 +71,51 @@ func Atmospheric_attenuation_slant_path(
 	}
 
 	if rho == 0 {
-		rho = 0.1
+		rho = 7.5 // itu835 参考大气的地面水汽密度
 	}
 
-	var Ar float64
+	if D == 0 {
+		D = 1.2
+	}
+
+	if eta == 0 {
+		eta = 0.5
+	}
+
+	if hL == 0 {
+		hL = 1000.0
+	}
+
+	if H == 0 {
+		// 由水汽密度换算相对湿度，见 itu453
+		e := rho * (T + 273.15) / 216.7
+		H = math.Min(100*e/SaturationVapourPressure(T), 100)
+	}
+
+	if L == 0 && includeClouds {
+		// 未给出液态水柱含量时取 P.840 在站点处的统计值，p < 5% 时按 5% 计算，见 itu618 2.5；
+		// 没有读入 P.840 数字地图时不计云衰减
+		var ok bool
+		if L, ok = CloudLiquidWater(lat, lon, math.Max(p, 5)); !ok {
+			includeClouds = false
+		}
+	}
+
+	var c Contributions
 
 	if includeRain {
 		//地面的经纬度lat, lon, 频率，倾角，地面站高度，不可用度，极化倾角，路径长度
 		//要传入R001
-		Ar = RainAttenuation(lat, lon, f, el, hs, p, R001, tau, Ls)
+		c.Rain = RainAttenuation(lat, lon, f, el, hs, p, R001, tau, Ls)
+	}
+	if includeGas {
+		c.Gas = GaseousAttenuationSlantPath(f, el, rho, P, T)
+	}
+	if includeClouds {
+		c.Cloud = CloudAttenuation(f, el, L)
+	}
+	if includeScintillation {
+		c.Scintillation = ScintillationAttenuation(f, el, p, D, eta, T, H, hL)
 	}
-	return Ar
+	return c
 }
@@ -0,0 +1,75 @@
+package itur
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// itu839
+
+var (
+	isothermHeightMu   sync.RWMutex
+	isothermHeightGrid *Grid
+)
+
+// LoadIsothermHeightGrid 读入 0°C 等温线高度 h0 (km) 的网格（格式见 Grid），
+// 之后 IsothermHeight 在网格上插值
+func LoadIsothermHeightGrid(r io.Reader) error {
+	g, err := ParseGrid(r)
+	if err != nil {
+		return err
+	}
+	setIsothermHeightGrid(g)
+	return nil
+}
+
+// LoadIsothermHeightMatrices 直接读入 ITU 发布的 P.839-4 数字地图：1.5° 网格的
+// ESALAT、ESALON 和 ESA0HEIGHT 三个矩阵文件，格式见 ParseGridMatrices
+func LoadIsothermHeightMatrices(lat, lon, h0 io.Reader) error {
+	g, err := ParseGridMatrices(lat, lon, h0)
+	if err != nil {
+		return fmt.Errorf("P.839 h0: %w", err)
+	}
+	setIsothermHeightGrid(g)
+	return nil
+}
+
+func setIsothermHeightGrid(g *Grid) {
+	isothermHeightMu.Lock()
+	defer isothermHeightMu.Unlock()
+	isothermHeightGrid = g
+}
+
+// IsothermHeight 年平均 0°C 等温线高度 h0 (km)。读入了 P.839-4 数字地图时在地图上双线性插值，
+// 否则使用 P.839-1 只与纬度有关的降雨高度模型，h0 = hR - 0.36。
+// 仓库不附带 ITU 的数字地图，需要 P.839-4 精度时由调用方读入
+func IsothermHeight(lat, lon float64) float64 {
+	isothermHeightMu.RLock()
+	g := isothermHeightGrid
+	isothermHeightMu.RUnlock()
+	if g != nil {
+		return g.Interpolate(lat, lon)
+	}
+	return math.Max(zonalRainHeight(lat)-0.36, 0)
+}
+
+// zonalRainHeight P.839-1 的降雨高度 hR (km)
+func zonalRainHeight(lat float64) float64 {
+	switch {
+	case lat > 23:
+		return 5 - 0.075*(lat-23)
+	case lat >= -21:
+		return 5
+	case lat >= -71:
+		return 5 + 0.1*(lat+21)
+	default:
+		return 0
+	}
+}
+
+// RainHeight 年平均降雨高度 hR = h0 + 0.36 (km)
+func RainHeight(lat, lon float64) float64 {
+	return IsothermHeight(lat, lon) + 0.36
+}
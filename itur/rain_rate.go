@@ -0,0 +1,129 @@
+package itur
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// itu837 附件 1，由月平均温度和月平均降雨量计算降雨率的年统计分布
+
+var (
+	monthlyRainMu      sync.RWMutex
+	monthlyTemperature [12]*Grid // 月平均地面温度 (K)
+	monthlyRainfall    [12]*Grid // 月平均降雨量 (mm)
+)
+
+// 各月的平均天数
+var daysInMonth = [12]float64{31, 28.25, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+// LoadMonthlyRainGrids 读入第 month (1-12) 月的平均温度 (K) 和平均降雨量 (mm) 网格，
+// 例如 ITU 发布的 P.837-7 数字地图。包内没有这些数据，12 个月都读入之后才能计算降雨率分布
+func LoadMonthlyRainGrids(month int, temperature, rainfall io.Reader) error {
+	if month < 1 || month > 12 {
+		return fmt.Errorf("month %d out of range", month)
+	}
+	T, err := ParseGrid(temperature)
+	if err != nil {
+		return fmt.Errorf("P.837 T month %02d: %w", month, err)
+	}
+	MT, err := ParseGrid(rainfall)
+	if err != nil {
+		return fmt.Errorf("P.837 MT month %02d: %w", month, err)
+	}
+	monthlyRainMu.Lock()
+	defer monthlyRainMu.Unlock()
+	monthlyTemperature[month-1], monthlyRainfall[month-1] = T, MT
+	return nil
+}
+
+// RainDistribution 一个位置的降雨率年统计分布
+type RainDistribution struct {
+	P0 [12]float64 // 各月降雨概率 (%)
+	R  [12]float64 // 各月降雨率分布的中位数 (mm/h)
+}
+
+// NewRainDistribution 由 (lat, lon) 处的月平均温度和降雨量得到降雨率分布，
+// 没有读入全部 12 个月的地图时 ok 为 false
+func NewRainDistribution(lat, lon float64) (d RainDistribution, ok bool) {
+	monthlyRainMu.RLock()
+	defer monthlyRainMu.RUnlock()
+	for m := 0; m < 12; m++ {
+		if monthlyTemperature[m] == nil || monthlyRainfall[m] == nil {
+			return RainDistribution{}, false
+		}
+	}
+	for m := 0; m < 12; m++ {
+		T := monthlyTemperature[m].Interpolate(lat, lon) - 273.15
+		MT := monthlyRainfall[m].Interpolate(lat, lon)
+		N := daysInMonth[m]
+
+		r := 0.5874
+		if T >= 0 {
+			r = 0.5874 * math.Exp(0.0883*T)
+		}
+		P0 := 100 * MT / (24 * N * r)
+		if P0 > 70 {
+			P0 = 70
+			r = 100 * MT / (70 * 24 * N)
+		}
+		d.P0[m], d.R[m] = P0, r
+	}
+	return d, true
+}
+
+// Probability 年平均降雨概率 P0 (%)
+func (d RainDistribution) Probability() float64 {
+	var sum float64
+	for m := 0; m < 12; m++ {
+		sum += daysInMonth[m] * d.P0[m]
+	}
+	return sum / 365.25
+}
+
+// Exceedance 降雨率超过 R (mm/h) 的年平均时间百分比 (%)
+func (d RainDistribution) Exceedance(R float64) float64 {
+	var sum float64
+	for m := 0; m < 12; m++ {
+		if d.P0[m] <= 0 || d.R[m] <= 0 {
+			continue
+		}
+		x := (math.Log(R) + 0.7938 - math.Log(d.R[m])) / 1.26
+		sum += daysInMonth[m] * d.P0[m] * 0.5 * math.Erfc(x/math.Sqrt2)
+	}
+	return sum / 365.25
+}
+
+// Rate 年平均时间的 p% 被超过的降雨率 Rp (mm/h)，p 不小于降雨概率时为 0
+func (d RainDistribution) Rate(p float64) float64 {
+	if p <= 0 || p >= d.Probability() {
+		return 0
+	}
+	// Exceedance 随 R 单调递减，在 ln R 上二分
+	lo, hi := math.Log(1e-3), math.Log(1e3)
+	for i := 0; i < 60; i++ {
+		mid := (lo + hi) / 2
+		if d.Exceedance(math.Exp(mid)) > p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return math.Exp((lo + hi) / 2)
+}
+
+// RainfallRate 在 (lat, lon) 处年平均时间的 p% 被超过的降雨率 (mm/h)，
+// 没有读入 P.837 地图时 ok 为 false
+func RainfallRate(lat, lon, p float64) (R float64, ok bool) {
+	d, ok := NewRainDistribution(lat, lon)
+	if !ok {
+		return 0, false
+	}
+	return d.Rate(p), true
+}
+
+// RainfallRate001 在 (lat, lon) 处年平均时间的 0.01% 被超过的降雨率 R0.01 (mm/h)
+func RainfallRate001(lat, lon float64) (R float64, ok bool) {
+	return RainfallRate(lat, lon, 0.01)
+}
@@ -0,0 +1,99 @@
+package itur
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// loadMonthlyRainGrids 读入由 T (K) 和 MT (mm) 生成的 5 度网格，测试结束后清除
+func loadMonthlyRainGrids(t *testing.T, months int, T, MT func(month int, lat float64) float64) {
+	t.Helper()
+	t.Cleanup(func() {
+		monthlyRainMu.Lock()
+		defer monthlyRainMu.Unlock()
+		monthlyTemperature, monthlyRainfall = [12]*Grid{}, [12]*Grid{}
+	})
+	grid := func(month int, f func(int, float64) float64) string {
+		var b strings.Builder
+		b.WriteString("-90 5 37 0 30 13\n")
+		for i := 0; i <= 36; i++ {
+			v := f(month, -90+5*float64(i))
+			b.WriteString(strings.Repeat(fmt.Sprintf(" %v", v), 13) + "\n")
+		}
+		return b.String()
+	}
+	for m := 1; m <= months; m++ {
+		if err := LoadMonthlyRainGrids(m, strings.NewReader(grid(m, T)), strings.NewReader(grid(m, MT))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRainDistributionRequiresMaps(t *testing.T) {
+	if _, ok := RainfallRate001(51.5, 0); ok {
+		t.Fatal("R0.01 available without P.837 maps")
+	}
+	// 缺少任何一个月都不能计算
+	constant := func(v float64) func(int, float64) float64 { return func(int, float64) float64 { return v } }
+	loadMonthlyRainGrids(t, 11, constant(293.15), constant(100))
+	if _, ok := NewRainDistribution(51.5, 0); ok {
+		t.Error("distribution available with 11 months loaded")
+	}
+	if err := LoadMonthlyRainGrids(13, strings.NewReader(""), strings.NewReader("")); err == nil {
+		t.Error("month 13: want error")
+	}
+	if err := LoadMonthlyRainGrids(12, strings.NewReader("bad"), strings.NewReader("")); err == nil {
+		t.Error("bad grid: want error")
+	}
+	if _, ok := NewRainDistribution(51.5, 0); ok {
+		t.Error("distribution available after a failed load")
+	}
+}
+
+func TestRainDistribution(t *testing.T) {
+	// 温度和降雨量随纬度和月份变化，0 度以下的月份使用 r = 0.5874
+	T := func(month int, lat float64) float64 {
+		return 273.15 + 28 - 0.5*math.Abs(lat) + 5*math.Cos(2*math.Pi*float64(month-7)/12)
+	}
+	MT := func(month int, lat float64) float64 {
+		return 20 + 200*math.Exp(-lat*lat/400) + 10*float64(month)
+	}
+	loadMonthlyRainGrids(t, 12, T, MT)
+
+	t.Run("monthly parameters", func(t *testing.T) {
+		d, ok := NewRainDistribution(60, 0)
+		if !ok {
+			t.Fatal("maps not loaded")
+		}
+		for m := 0; m < 12; m++ {
+			Tc := T(m+1, 60) - 273.15
+			r := 0.5874
+			if Tc >= 0 {
+				r *= math.Exp(0.0883 * Tc)
+			}
+			checkRel(t, fmt.Sprintf("r month %d", m+1), d.R[m], r, 1e-6)
+			checkRel(t, fmt.Sprintf("P0 month %d", m+1), d.P0[m], 100*MT(m+1, 60)/(24*daysInMonth[m]*r), 1e-6)
+		}
+	})
+
+	for _, ll := range [][2]float64{{0, 0}, {40, -3}, {51.5, 0}, {-30, 150}, {65, 20}} {
+		t.Run(fmt.Sprintf("%g,%g", ll[0], ll[1]), func(t *testing.T) {
+			d, ok := NewRainDistribution(ll[0], ll[1])
+			if !ok {
+				t.Fatal("maps not loaded")
+			}
+			for _, p := range []float64{0.01, 0.1, 1} {
+				checkRel(t, fmt.Sprintf("Exceedance(Rate(%g))", p), d.Exceedance(d.Rate(p)), p, 1e-6)
+			}
+			if r := d.Rate(d.Probability()); r != 0 {
+				t.Errorf("Rate(P0) = %g, want 0", r)
+			}
+			R001, ok := RainfallRate001(ll[0], ll[1])
+			if !ok || R001 <= d.Rate(0.1) {
+				t.Errorf("R0.01 = %g (ok %v), want above R0.1 = %g", R001, ok, d.Rate(0.1))
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package itur
+
+import (
+	"demokubenet/utils"
+	"math"
+)
+
+// itu618 2.4.1
+
+// SaturationVapourPressure 水面的饱和水汽压 (hPa)，T: 温度 (°C)，见 itu453
+func SaturationVapourPressure(T float64) float64 {
+	return 6.1121 * math.Exp(17.502*T/(T+240.97))
+}
+
+// WetTermRadioRefractivity 折射率湿项 Nwet，T: 温度 (°C)，H: 相对湿度 (%)
+func WetTermRadioRefractivity(T, H float64) float64 {
+	e := H * SaturationVapourPressure(T) / 100
+	return 3.732e5 * e / math.Pow(273+T, 2)
+}
+
+// ScintillationAttenuation 对流层闪烁在 p% 时间内超过的衰落深度 (dB)
+// f: 频率 (GHz), el: 仰角 (度), p: 时间百分比, D: 天线直径 (m), eta: 天线效率,
+// T: 地面温度 (°C), H: 相对湿度 (%), hL: 湍流层高度 (m)
+func ScintillationAttenuation(f, el, p, D, eta, T, H, hL float64) float64 {
+	sinEl := math.Sin(utils.DegToRad(el))
+
+	// step 1-2
+	sigmaRef := 3.6e-3 + 1e-4*WetTermRadioRefractivity(T, H)
+
+	// step 3: 有效路径长度 (m)
+	L := 2 * hL / (math.Sqrt(sinEl*sinEl+2.35e-4) + sinEl)
+
+	// step 4-5: 天线平均因子
+	Deff := math.Sqrt(eta) * D
+	x := 1.22 * Deff * Deff * f / L
+	g2 := 3.86*math.Pow(x*x+1, 11.0/12)*math.Sin(11.0/6*math.Atan(1/x)) - 7.08*math.Pow(x, 5.0/6)
+	if g2 <= 0 {
+		return 0
+	}
+	g := math.Sqrt(g2)
+
+	// step 6
+	sigma := sigmaRef * math.Pow(f, 7.0/12) * g / math.Pow(sinEl, 1.2)
+
+	// step 7
+	lp := math.Log10(p)
+	a := -0.061*lp*lp*lp + 0.072*lp*lp - 1.71*lp + 3.0
+
+	return a * sigma
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	internal "demokubenet/internal"
 	"fmt"
 	"log"
@@ -11,8 +12,8 @@ import (
 )
 
 func main() {
-	if len(os.Args) != 4 {
-		log.Fatalf("Usage: %s <station_num> <satellite_nums> <round>", os.Args[0])
+	if len(os.Args) < 4 || len(os.Args) == 5 || len(os.Args) > 7 {
+		log.Fatalf("Usage: %s <station_num> <satellite_nums> <round> [<start_epoch_rfc3339> <step> [<mobile_terminals_file>]]", os.Args[0])
 	}
 	// 解析参数
 	stationCount, err1 := strconv.Atoi(os.Args[1])
@@ -21,6 +22,9 @@ func main() {
 	if err1 != nil || err2 != nil || err3 != nil {
 		log.Fatalf("Invalid arguments: %v, %v, %v", err1, err2, err3)
 	}
+	if round < 1 {
+		log.Fatalf("Invalid round: %d, must be positive", round)
+	}
 	fmt.Printf("KubeDemo running with %d stations and %d satellites for %d rounds\n", stationCount, satelliteCount, round)
 
 	// for i := 0; i < 20; i++ {
@@ -33,17 +37,51 @@ func main() {
 		log.Printf("failed to create EmulationInstance: %v", err)
 	}
 
+	// 指定起始历元和步长时使用仿真时钟，保证结果可复现
+	var step time.Duration
+	if len(os.Args) >= 6 {
+		start, err := time.Parse(time.RFC3339, os.Args[4])
+		if err != nil {
+			log.Fatalf("Invalid start epoch: %v", err)
+		}
+		step, err = time.ParseDuration(os.Args[5])
+		if err != nil {
+			log.Fatalf("Invalid step: %v", err)
+		}
+		inst.SetClock(internal.NewSimulatedClock(start, step))
+	}
+	// 移动终端的轨迹从仿真起始时刻出发
+	if len(os.Args) == 7 {
+		mobile, err := inst.LoadMobileStations(os.Args[6])
+		if err != nil {
+			log.Fatalf("Invalid mobile terminals: %v", err)
+		}
+		fmt.Printf("Added %d mobile terminals\n", len(mobile))
+	}
+
+	inst.Scheduler.SetDeadLetterHandler(func(evt internal.Event, err error) {
+		log.Printf("round at %v failed: %v", evt.Time, err)
+	})
+
 	// 启动scheduler
 	inst.Start()
 
-	for i := 0; i < round; i++ {
+	if step > 0 {
+		// 由调度器按仿真时间周期触发链路计算
 		var wg sync.WaitGroup
-		wg.Add(1)
-
-		inst.Scheduler.PublishWithWait(internal.Event{Type: internal.EasyEvent}, &wg)
-
+		wg.Add(round)
+		if _, err := inst.ScheduleLinkUpdates(step, round, &wg); err != nil {
+			log.Fatalf("Invalid step: %v", err)
+		}
 		wg.Wait()
-		log.Printf("Run %d complete\n", i+1)
+		log.Printf("Run %d rounds complete\n", round)
+	} else {
+		for i := 0; i < round; i++ {
+			if err := inst.Scheduler.Publish(inst.NewTickEvent(internal.EasyEvent)).Wait(); err != nil {
+				log.Fatalf("Run %d failed: %v", i+1, err)
+			}
+			log.Printf("Run %d complete\n", i+1)
+		}
 	}
 
 	// 验证所有LinkCache中的Ar被更新
@@ -53,6 +91,14 @@ func main() {
 	// 	fmt.Println("i = ", i, "link.Ar = ", link.Ar)
 	// }
 
+	log.Printf("scheduler stats: %+v", inst.Scheduler.Stats())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := inst.Stop(ctx); err != nil {
+		log.Printf("failed to stop EmulationInstance: %v", err)
+	}
+
 	endTime := time.Now()
 	log.Println("interval = ", endTime.Sub(startTime))
 	// }
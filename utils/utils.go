@@ -36,6 +36,10 @@ func DegToRad(deg float64) float64 {
 	return deg * math.Pi / 180
 }
 
+func RadToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
 func clip(value, min, max float64) float64 {
 	if value < min {
 		return min